@@ -1,58 +1,272 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
+	"strings"
 
 	"github.com/go-ini/ini"
+	"github.com/liuys-dase/csc-tree/filter/basicfilter"
 )
 
 type ServerConfig struct {
-	CSCTreeConfig *CSCTreeConfig
+	CSCTreeConfig *CSCTreeConfig `json:"CSCTree" yaml:"CSCTree"`
 }
 
-func NewServerConfig(iniPath string) *ServerConfig {
+func NewServerConfig(iniPath string) (*ServerConfig, error) {
+	return NewServerConfigWithProfile(iniPath, "")
+}
+
+// 读取 INI 文件中的 [CSCTree] 以及 [CSCTree.<profile>]
+func NewServerConfigWithProfile(iniPath string, profile string) (*ServerConfig, error) {
 	ini, err := readConfig(iniPath)
 	if err != nil {
-		log.Fatal("Error loading config:", err)
+		return nil, err
 	}
-	return &ServerConfig{
-		CSCTreeConfig: NewCSCTreeConfig(ini),
+	cscTreeConfig, err := NewCSCTreeConfigWithProfile(ini, profile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", iniPath, err)
 	}
+	return &ServerConfig{
+		CSCTreeConfig: cscTreeConfig,
+	}, nil
 }
 
 type CSCTreeConfig struct {
-	MaxLevel            int
-	BfFalsePositiveRate float64
-	BfHashFuncNum       int
-	FingerprintSize     int
-	FingerprintNum      int
-	MaxKickAttempts     int
-	PartitionNum        int
-	RepetitionNum       int
-	MaxElementNumPerPar int
-	SketchLevel         int
-	UseNodeIndex        bool
-	LeafNum             int
-	UseFlatten          bool
-}
-
-func NewCSCTreeConfig(ini *ini.File) *CSCTreeConfig {
+	MaxLevel            int     `json:"MaxLevel" yaml:"MaxLevel"`                       // CSCTree 的最大层高，>= 1，根节点达到该层高后创建新的 CSCTree
+	BfFalsePositiveRate float64 `json:"BfFalsePositiveRate" yaml:"BfFalsePositiveRate"` // BloomFilter 的误判率，(0, 1)
+	BfHashFuncNum       int     `json:"BfHashFuncNum" yaml:"BfHashFuncNum"`             // BloomFilter 的哈希函数数量，>= 1
+	FingerprintSize     int     `json:"FingerprintSize" yaml:"FingerprintSize"`         // CSC 指纹的位数，[1, 64]
+	FingerprintNum      int     `json:"FingerprintNum" yaml:"FingerprintNum"`           // CSC 每个 bucket 的 slot 数量，>= 1
+	MaxKickAttempts     int     `json:"MaxKickAttempts" yaml:"MaxKickAttempts"`         // CSC 插入时最大的踢出次数，>= 1
+	PartitionNum        int     `json:"PartitionNum" yaml:"PartitionNum"`               // CSC 的分区数量，>= 1
+	RepetitionNum       int     `json:"RepetitionNum" yaml:"RepetitionNum"`             // CSCR 中 CSC 的数量，>= 1
+	MaxElementNumPerPar int     `json:"MaxElementNumPerPar" yaml:"MaxElementNumPerPar"` // 估计 CSC 大小时每个分区的元素数量，>= 1
+	SketchLevel         int     `json:"SketchLevel" yaml:"SketchLevel"`                 // 低于该层级的 CSCR 使用 HashMap，[0, MaxLevel]
+	UseNodeIndex        bool    `json:"UseNodeIndex" yaml:"UseNodeIndex"`
+	LeafNum             int     `json:"LeafNum" yaml:"LeafNum"` // FlattenNode 合并的叶子节点数量，>= 2 的 2 的幂
+	UseFlatten          bool    `json:"UseFlatten" yaml:"UseFlatten"`
+	Seed                int64   `json:"Seed" yaml:"Seed"` // 构建时使用的随机种子，相同的种子与数据得到完全相同的结构；0 表示每次使用不同的种子
+	// 哈希密钥，非空时节点过滤器、CSC 与分区的哈希种子都由密钥派生，防止攻击者构造冲突的地址；为空时使用公开的种子。
+	// 除 CF 使用 HMAC-MD5 外，派生的种子只作为 xxhash 的种子，xxhash 不是带密钥的伪随机函数：
+	// 不知道密钥时无法直接算出哈希值，但不能排除与种子无关的冲突，也不能抵抗可以观察大量查询结果的攻击者
+	HashKey string `json:"HashKey" yaml:"HashKey"`
+	// 构建 CSCR 时检测负载异常的 bucket，记录在 TreeStats 中
+	DetectAnomalies bool `json:"DetectAnomalies" yaml:"DetectAnomalies"`
+	// 节点过滤器的类型（basicfilter.FilterTypes 中的类型名），为空时使用 BF；BfFalsePositiveRate 同样用于 CF 与 BBF（BBF 还使用 BfHashFuncNum），BFUSE 的误判率固定约为 1/256
+	NodeFilter string `json:"NodeFilter" yaml:"NodeFilter"`
+	// 按层级覆盖 NodeFilter，格式为 "level:type,level:type"，例如 "1:CF,2:CF"；层级为过滤器所在节点的层级
+	LevelFilters string `json:"LevelFilters" yaml:"LevelFilters"`
+	// 节点的 CSCR 开启引用计数，之后可以用 Remove 删除 (item, fileId)；相同的指纹分别存放，占用更多空间
+	RefCount bool `json:"RefCount" yaml:"RefCount"`
+}
+
+// 默认配置，与仓库中的 config.ini 一致
+func DefaultCSCTreeConfig() *CSCTreeConfig {
 	return &CSCTreeConfig{
-		MaxLevel:            ini.Section("CSCTree").Key("MaxLevel").MustInt(),
-		BfFalsePositiveRate: ini.Section("CSCTree").Key("BfFalsePositiveRate").MustFloat64(),
-		BfHashFuncNum:       ini.Section("CSCTree").Key("BfHashFuncNum").MustInt(),
-		FingerprintSize:     ini.Section("CSCTree").Key("FingerprintSize").MustInt(),
-		FingerprintNum:      ini.Section("CSCTree").Key("FingerprintNum").MustInt(),
-		MaxKickAttempts:     ini.Section("CSCTree").Key("MaxKickAttempts").MustInt(),
-		PartitionNum:        ini.Section("CSCTree").Key("PartitionNum").MustInt(),
-		RepetitionNum:       ini.Section("CSCTree").Key("RepetitionNum").MustInt(),
-		MaxElementNumPerPar: ini.Section("CSCTree").Key("MaxElementNumPerPar").MustInt(),
-		SketchLevel:         ini.Section("CSCTree").Key("SketchLevel").MustInt(),
-		UseNodeIndex:        ini.Section("CSCTree").Key("UseNodeIndex").MustBool(),
-		LeafNum:             ini.Section("CSCTree").Key("LeafNum").MustInt(),
-		UseFlatten:          ini.Section("CSCTree").Key("UseFlatten").MustBool(),
+		MaxLevel:            11,
+		BfFalsePositiveRate: 0.01,
+		BfHashFuncNum:       7,
+		FingerprintSize:     8,
+		FingerprintNum:      4,
+		MaxKickAttempts:     30,
+		PartitionNum:        16,
+		RepetitionNum:       3,
+		MaxElementNumPerPar: 5,
+		SketchLevel:         0,
+		UseNodeIndex:        true,
+		LeafNum:             4,
+		UseFlatten:          false,
+		Seed:                0,
+		HashKey:             "",
+		DetectAnomalies:     false,
+		NodeFilter:          basicfilter.FILTER_BLOOM,
+		LevelFilters:        "",
+		RefCount:            false,
+	}
+}
+
+// 返回隐藏了 HashKey 的副本，用于输出报告
+func (c *CSCTreeConfig) Redacted() *CSCTreeConfig {
+	r := *c
+	if r.HashKey != "" {
+		r.HashKey = "REDACTED"
+	}
+	return &r
+}
+
+// 解析 LevelFilters，返回 level -> 过滤器类型
+func (c *CSCTreeConfig) ParseLevelFilters() (map[int]string, error) {
+	res := make(map[int]string)
+	if strings.TrimSpace(c.LevelFilters) == "" {
+		return res, nil
+	}
+	for _, entry := range strings.Split(c.LevelFilters, ",") {
+		levelStr, name, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("entry %q must be level:type", entry)
+		}
+		level, err := strconv.Atoi(strings.TrimSpace(levelStr))
+		if err != nil {
+			return nil, fmt.Errorf("entry %q: level must be an integer", entry)
+		}
+		if _, ok := res[level]; ok {
+			return nil, fmt.Errorf("level %d is set more than once", level)
+		}
+		res[level] = strings.TrimSpace(name)
+	}
+	return res, nil
+}
+
+// 每一层节点使用的过滤器类型，下标为节点的层级，长度为 MaxLevel + 1。
+// LevelFilters 中未指定的层级使用 NodeFilter，NodeFilter 为空时使用 BloomFilter；格式错误或类型未知时返回错误
+func (c *CSCTreeConfig) LevelFilterTypes() ([]string, error) {
+	levels, err := c.ParseLevelFilters()
+	if err != nil {
+		return nil, err
+	}
+	nodeFilter := c.NodeFilter
+	if nodeFilter == "" {
+		nodeFilter = basicfilter.FILTER_BLOOM
+	}
+	if !basicfilter.IsFilterType(nodeFilter) {
+		return nil, fmt.Errorf("unknown filter type %q", nodeFilter)
+	}
+	res := make([]string, max(c.MaxLevel+1, 0))
+	for i := range res {
+		res[i] = nodeFilter
+	}
+	for level, name := range levels {
+		if level < 1 || level >= c.MaxLevel {
+			return nil, fmt.Errorf("level %d must be in [1, MaxLevel=%d)", level, c.MaxLevel)
+		}
+		if !basicfilter.IsFilterType(name) {
+			return nil, fmt.Errorf("unknown filter type %q at level %d", name, level)
+		}
+		res[level] = name
+	}
+	return res, nil
+}
+
+// 从 [CSCTree] 读取配置，缺失的键使用默认值，格式错误或校验失败时返回错误
+func NewCSCTreeConfig(ini *ini.File) (*CSCTreeConfig, error) {
+	return NewCSCTreeConfigWithProfile(ini, "")
+}
+
+// 先读取 [CSCTree]，再使用 [CSCTree.<profile>] 中的键覆盖，profile 为空时只读取 [CSCTree]
+func NewCSCTreeConfigWithProfile(ini *ini.File, profile string) (*CSCTreeConfig, error) {
+	c := DefaultCSCTreeConfig()
+	if err := c.applySection(ini.Section("CSCTree")); err != nil {
+		return nil, err
+	}
+	if profile != "" {
+		section, err := ini.GetSection("CSCTree." + profile)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q not found", profile)
+		}
+		if err := c.applySection(section); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 使用 section 中存在的键覆盖配置
+func (c *CSCTreeConfig) applySection(section *ini.Section) error {
+	errs := make([]error, 0)
+	for _, f := range c.fields() {
+		if section.HasKey(f.name) {
+			if err := f.set(section.Key(f.name).String()); err != nil {
+				errs = append(errs, err)
+			}
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// 配置项的名称以及从字符串解析的方法，INI 与环境变量共用
+type field struct {
+	name string
+	set  func(value string) error
+}
+
+func (c *CSCTreeConfig) fields() []field {
+	return []field{
+		intField("MaxLevel", &c.MaxLevel),
+		float64Field("BfFalsePositiveRate", &c.BfFalsePositiveRate),
+		intField("BfHashFuncNum", &c.BfHashFuncNum),
+		intField("FingerprintSize", &c.FingerprintSize),
+		intField("FingerprintNum", &c.FingerprintNum),
+		intField("MaxKickAttempts", &c.MaxKickAttempts),
+		intField("PartitionNum", &c.PartitionNum),
+		intField("RepetitionNum", &c.RepetitionNum),
+		intField("MaxElementNumPerPar", &c.MaxElementNumPerPar),
+		intField("SketchLevel", &c.SketchLevel),
+		boolField("UseNodeIndex", &c.UseNodeIndex),
+		intField("LeafNum", &c.LeafNum),
+		boolField("UseFlatten", &c.UseFlatten),
+		int64Field("Seed", &c.Seed),
+		stringField("HashKey", &c.HashKey),
+		boolField("DetectAnomalies", &c.DetectAnomalies),
+		stringField("NodeFilter", &c.NodeFilter),
+		stringField("LevelFilters", &c.LevelFilters),
+		boolField("RefCount", &c.RefCount),
+	}
+}
+
+func intField(name string, dst *int) field {
+	return field{name: name, set: func(value string) error {
+		v, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return &FieldError{Field: name, Value: value, Reason: "must be an integer"}
+		}
+		*dst = v
+		return nil
+	}}
+}
+
+func int64Field(name string, dst *int64) field {
+	return field{name: name, set: func(value string) error {
+		v, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return &FieldError{Field: name, Value: value, Reason: "must be an integer"}
+		}
+		*dst = v
+		return nil
+	}}
+}
+
+func float64Field(name string, dst *float64) field {
+	return field{name: name, set: func(value string) error {
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return &FieldError{Field: name, Value: value, Reason: "must be a number"}
+		}
+		*dst = v
+		return nil
+	}}
+}
+
+func stringField(name string, dst *string) field {
+	return field{name: name, set: func(value string) error {
+		*dst = value
+		return nil
+	}}
+}
+
+func boolField(name string, dst *bool) field {
+	return field{name: name, set: func(value string) error {
+		v, err := parseBool(value)
+		if err != nil {
+			return &FieldError{Field: name, Value: value, Reason: "must be a boolean"}
+		}
+		*dst = v
+		return nil
+	}}
 }
 
 // 读取配置文件
@@ -63,3 +277,14 @@ func readConfig(filePath string) (*ini.File, error) {
 	}
 	return ini, nil
 }
+
+// 与 go-ini 一致，除 strconv.ParseBool 支持的写法外还支持 yes/no、on/off
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "on":
+		return true, nil
+	case "no", "n", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(value))
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-ini/ini"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadString(t *testing.T, data string) (*CSCTreeConfig, error) {
+	f, err := ini.Load([]byte(data))
+	assert.Nil(t, err)
+	return NewCSCTreeConfig(f)
+}
+
+func TestNewCSCTreeConfig(t *testing.T) {
+	// 缺失的键使用默认值
+	c, err := loadString(t, "[CSCTree]\nMaxLevel = 6\nUseFlatten = true\n")
+	assert.Nil(t, err)
+	expected := DefaultCSCTreeConfig()
+	expected.MaxLevel = 6
+	expected.UseFlatten = true
+	assert.Equal(t, expected, c)
+
+	_, err = loadString(t, "[CSCTree]\nPartitionNum = abc\n")
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe))
+	assert.Equal(t, "PartitionNum", fe.Field)
+
+	_, err = loadString(t, "[CSCTree]\nPartitionNum = 0\n")
+	assert.ErrorContains(t, err, "PartitionNum")
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, DefaultCSCTreeConfig().Validate())
+	cases := map[string]func(c *CSCTreeConfig){
+		"MaxLevel":            func(c *CSCTreeConfig) { c.MaxLevel = 0 },
+		"BfFalsePositiveRate": func(c *CSCTreeConfig) { c.BfFalsePositiveRate = 1 },
+		"FingerprintSize":     func(c *CSCTreeConfig) { c.FingerprintSize = 65 },
+		"LeafNum":             func(c *CSCTreeConfig) { c.LeafNum = 6 },
+		"SketchLevel":         func(c *CSCTreeConfig) { c.SketchLevel = 12 },
+		"NodeFilter":          func(c *CSCTreeConfig) { c.NodeFilter = "XF" },
+		"LevelFilters":        func(c *CSCTreeConfig) { c.LevelFilters = "1:XF" },
+	}
+	for field, modify := range cases {
+		c := DefaultCSCTreeConfig()
+		modify(c)
+		err := c.Validate()
+		var fe *FieldError
+		assert.True(t, errors.As(err, &fe), field)
+		assert.Equal(t, field, fe.Field)
+	}
+	// 跨字段：FlattenNode 合并后的层高超过 MaxLevel
+	c := DefaultCSCTreeConfig()
+	c.UseFlatten = true
+	c.LeafNum = 8
+	c.MaxLevel = 4
+	assert.ErrorContains(t, c.Validate(), "UseFlatten")
+	c.MaxLevel = 5
+	assert.Nil(t, c.Validate())
+	// 返回所有错误
+	c = DefaultCSCTreeConfig()
+	c.PartitionNum = 0
+	c.RepetitionNum = 0
+	assert.ErrorContains(t, c.Validate(), "PartitionNum")
+	assert.ErrorContains(t, c.Validate(), "RepetitionNum")
+}
+
+func TestLevelFilterTypes(t *testing.T) {
+	c, err := loadString(t, "[CSCTree]\nNodeFilter = CF\nLevelFilters = 1:BF, 3:BF\n")
+	assert.Nil(t, err)
+	filters, err := c.LevelFilterTypes()
+	assert.Nil(t, err)
+	assert.Equal(t, c.MaxLevel+1, len(filters))
+	assert.Equal(t, "BF", filters[1])
+	assert.Equal(t, "CF", filters[2])
+	assert.Equal(t, "BF", filters[3])
+	// NodeFilter 为空时使用 BF
+	c.NodeFilter = ""
+	assert.Nil(t, c.Validate())
+	filters, _ = c.LevelFilterTypes()
+	assert.Equal(t, "BF", filters[2])
+	// 未知的类型返回错误，不会退回 BF
+	c.NodeFilter = "XF"
+	_, err = c.LevelFilterTypes()
+	assert.ErrorContains(t, err, "XF")
+
+	for _, levels := range []string{"1", "a:BF", "1:BF,1:CF", "11:BF"} {
+		c := DefaultCSCTreeConfig()
+		c.LevelFilters = levels
+		assert.ErrorContains(t, c.Validate(), "LevelFilters", levels)
+	}
+}
+
+func TestNewServerConfig(t *testing.T) {
+	conf, err := NewServerConfig("../config.ini")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultCSCTreeConfig(), conf.CSCTreeConfig)
+
+	_, err = NewServerConfig(filepath.Join(t.TempDir(), "missing.ini"))
+	assert.NotNil(t, err)
+
+	path := filepath.Join(t.TempDir(), "bad.ini")
+	assert.Nil(t, os.WriteFile(path, []byte("[CSCTree]\nLeafNum = 3\n"), 0644))
+	_, err = NewServerConfig(path)
+	assert.ErrorContains(t, err, "LeafNum")
+}
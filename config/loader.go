@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultEnvPrefix = "CSCTREE_"
+
+// 读取 JSON 配置文件，格式为 {"CSCTree": {...}}，缺失的键使用默认值
+func LoadJSON(path string) (*ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	conf := &ServerConfig{CSCTreeConfig: DefaultCSCTreeConfig()}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// 拼写错误的键直接报错，而不是静默使用默认值
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(conf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return conf.validate(path)
+}
+
+// 读取 YAML 配置文件，格式与 JSON 相同
+func LoadYAML(path string) (*ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	conf := &ServerConfig{CSCTreeConfig: DefaultCSCTreeConfig()}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// 空文件返回 io.EOF，此时使用默认配置
+	if err := decoder.Decode(conf); err != nil && len(bytes.TrimSpace(data)) > 0 {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return conf.validate(path)
+}
+
+// 根据扩展名选择 INI、JSON 或 YAML
+func LoadFile(path string) (*ServerConfig, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return LoadJSON(path)
+	case ".yaml", ".yml":
+		return LoadYAML(path)
+	case ".ini":
+		return NewServerConfig(path)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", filepath.Ext(path))
+	}
+}
+
+func (conf *ServerConfig) validate(path string) (*ServerConfig, error) {
+	if conf.CSCTreeConfig == nil {
+		conf.CSCTreeConfig = DefaultCSCTreeConfig()
+	}
+	if err := conf.CSCTreeConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// 默认配置加上环境变量的覆盖
+func FromEnv(prefix string) (*CSCTreeConfig, error) {
+	c := DefaultCSCTreeConfig()
+	if err := c.ApplyEnv(prefix); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 使用环境变量覆盖配置，变量名为 prefix 加上大写下划线形式的配置名，例如 CSCTREE_MAX_LEVEL
+// 只返回解析错误，调用者需要在所有覆盖完成后调用 Validate
+func (c *CSCTreeConfig) ApplyEnv(prefix string) error {
+	errs := make([]error, 0)
+	for _, f := range c.fields() {
+		if value, ok := os.LookupEnv(EnvName(prefix, f.name)); ok {
+			if err := f.set(value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 配置名对应的环境变量名，例如 BfFalsePositiveRate -> CSCTREE_BF_FALSE_POSITIVE_RATE
+func EnvName(prefix string, name string) string {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(runes[i-1]) {
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-ini/ini"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name string, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(data), 0644))
+	return path
+}
+
+func TestLoadJSONAndYAML(t *testing.T) {
+	expected := DefaultCSCTreeConfig()
+	expected.FingerprintSize = 16
+	expected.UseFlatten = true
+
+	conf, err := LoadFile(writeFile(t, "c.json", `{"CSCTree": {"FingerprintSize": 16, "UseFlatten": true}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, conf.CSCTreeConfig)
+
+	conf, err = LoadFile(writeFile(t, "c.yaml", "CSCTree:\n  FingerprintSize: 16\n  UseFlatten: true\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, conf.CSCTreeConfig)
+
+	conf, err = LoadFile(writeFile(t, "c.yml", ""))
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultCSCTreeConfig(), conf.CSCTreeConfig)
+
+	// 拼写错误的键
+	_, err = LoadJSON(writeFile(t, "c.json", `{"CSCTree": {"FingerprintSzie": 16}}`))
+	assert.NotNil(t, err)
+	_, err = LoadYAML(writeFile(t, "c.yaml", "CSCTree:\n  FingerprintSzie: 16\n"))
+	assert.NotNil(t, err)
+	// 校验失败
+	_, err = LoadJSON(writeFile(t, "c.json", `{"CSCTree": {"LeafNum": 3}}`))
+	assert.ErrorContains(t, err, "LeafNum")
+	_, err = LoadFile(writeFile(t, "c.toml", ""))
+	assert.NotNil(t, err)
+}
+
+func TestApplyEnv(t *testing.T) {
+	assert.Equal(t, "CSCTREE_BF_FALSE_POSITIVE_RATE", EnvName(DefaultEnvPrefix, "BfFalsePositiveRate"))
+	assert.Equal(t, "CSCTREE_MAX_ELEMENT_NUM_PER_PAR", EnvName(DefaultEnvPrefix, "MaxElementNumPerPar"))
+	t.Setenv("CSCTREE_MAX_LEVEL", "6")
+	t.Setenv("CSCTREE_USE_FLATTEN", "yes")
+	t.Setenv("CSCTREE_BF_FALSE_POSITIVE_RATE", "0.001")
+	t.Setenv("CSCTREE_SEED", "42")
+	c, err := FromEnv(DefaultEnvPrefix)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, c.MaxLevel)
+	assert.Equal(t, int64(42), c.Seed)
+	assert.True(t, c.UseFlatten)
+	assert.Equal(t, 0.001, c.BfFalsePositiveRate)
+
+	t.Setenv("CSCTREE_PARTITION_NUM", "many")
+	_, err = FromEnv(DefaultEnvPrefix)
+	assert.ErrorContains(t, err, "PartitionNum")
+}
+
+func TestProfile(t *testing.T) {
+	f, err := ini.Load([]byte("[CSCTree]\nMaxLevel = 8\nLeafNum = 4\n\n[CSCTree.flatten]\nUseFlatten = true\nLeafNum = 8\n"))
+	assert.Nil(t, err)
+	c, err := NewCSCTreeConfigWithProfile(f, "flatten")
+	assert.Nil(t, err)
+	assert.Equal(t, 8, c.MaxLevel)
+	assert.Equal(t, 8, c.LeafNum)
+	assert.True(t, c.UseFlatten)
+	// 不指定 profile 时忽略 [CSCTree.flatten]
+	c, err = NewCSCTreeConfig(f)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, c.LeafNum)
+	assert.False(t, c.UseFlatten)
+	_, err = NewCSCTreeConfigWithProfile(f, "missing")
+	assert.ErrorContains(t, err, "missing")
+}
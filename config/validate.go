@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/liuys-dase/csc-tree/filter/basicfilter"
+)
+
+// 单个配置项的错误
+type FieldError struct {
+	Field  string
+	Value  any
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid %s = %v: %s", e.Field, e.Value, e.Reason)
+}
+
+// 校验配置，返回所有不合法的配置项（可以通过 errors.As 取出 *FieldError）
+func (c *CSCTreeConfig) Validate() error {
+	errs := make([]error, 0)
+	check := func(ok bool, field string, value any, reason string) {
+		if !ok {
+			errs = append(errs, &FieldError{Field: field, Value: value, Reason: reason})
+		}
+	}
+	check(c.MaxLevel >= 1, "MaxLevel", c.MaxLevel, "must be >= 1")
+	check(c.BfFalsePositiveRate > 0 && c.BfFalsePositiveRate < 1, "BfFalsePositiveRate", c.BfFalsePositiveRate, "must be in (0, 1)")
+	check(c.BfHashFuncNum >= 1, "BfHashFuncNum", c.BfHashFuncNum, "must be >= 1")
+	check(c.FingerprintSize >= 1 && c.FingerprintSize <= 64, "FingerprintSize", c.FingerprintSize, "must be in [1, 64]")
+	check(c.FingerprintNum >= 1, "FingerprintNum", c.FingerprintNum, "must be >= 1")
+	check(c.MaxKickAttempts >= 1, "MaxKickAttempts", c.MaxKickAttempts, "must be >= 1")
+	check(c.PartitionNum >= 1, "PartitionNum", c.PartitionNum, "must be >= 1")
+	check(c.RepetitionNum >= 1, "RepetitionNum", c.RepetitionNum, "must be >= 1")
+	check(c.MaxElementNumPerPar >= 1, "MaxElementNumPerPar", c.MaxElementNumPerPar, "must be >= 1")
+	check(c.LeafNum >= 2 && c.LeafNum&(c.LeafNum-1) == 0, "LeafNum", c.LeafNum, "must be a power of two >= 2")
+	check(c.NodeFilter == "" || basicfilter.IsFilterType(c.NodeFilter), "NodeFilter", c.NodeFilter, fmt.Sprintf("must be empty or one of %v", basicfilter.FilterTypes()))
+	// 跨字段的校验
+	check(c.SketchLevel >= 0 && c.SketchLevel <= c.MaxLevel, "SketchLevel", c.SketchLevel, fmt.Sprintf("must be in [0, MaxLevel=%d]", c.MaxLevel))
+	if c.UseFlatten {
+		// 两个 FlattenNode 合并后的层高不能超过 MaxLevel
+		flattenLevel := 1
+		for n := c.LeafNum; n > 1; n >>= 1 {
+			flattenLevel++
+		}
+		check(c.MaxLevel >= flattenLevel+1, "MaxLevel", c.MaxLevel, fmt.Sprintf("must be >= %d when UseFlatten with LeafNum=%d", flattenLevel+1, c.LeafNum))
+	}
+	if levels, err := c.ParseLevelFilters(); err != nil {
+		check(false, "LevelFilters", c.LevelFilters, err.Error())
+	} else {
+		sorted := make([]int, 0, len(levels))
+		for level := range levels {
+			sorted = append(sorted, level)
+		}
+		sort.Ints(sorted)
+		for _, level := range sorted {
+			name := levels[level]
+			check(level >= 1 && level < c.MaxLevel, "LevelFilters", c.LevelFilters, fmt.Sprintf("level %d must be in [1, MaxLevel=%d)", level, c.MaxLevel))
+			check(basicfilter.IsFilterType(name), "LevelFilters", c.LevelFilters, fmt.Sprintf("type %q must be one of %v", name, basicfilter.FilterTypes()))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
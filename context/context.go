@@ -1,29 +1,83 @@
 package context
 
 import (
+	"math/rand"
+	"sync"
+	"time"
+
 	"github.com/liuys-dase/csc-tree/config"
 )
 
 type Context struct {
 	Config *config.ServerConfig
+	// 构建时所有随机数（CSC 的哈希种子、踢出位置等）的来源，NewContext* 根据 Config 中的 Seed 创建；
+	// 直接构造 Context 时可以为 nil，第一次调用 Rand 时创建
+	Rng     *rand.Rand
+	rngOnce sync.Once
+}
+
+// 返回随机数来源，Seed 为 0 时使用当前时间作为种子；多个 goroutine 同时第一次调用时只会创建一个
+func (ctx *Context) Rand() *rand.Rand {
+	ctx.rngOnce.Do(func() {
+		if ctx.Rng == nil {
+			ctx.Rng = NewRand(ctx.Config.CSCTreeConfig.Seed)
+		}
+	})
+	return ctx.Rng
+}
+
+func newContext(conf *config.ServerConfig) *Context {
+	return &Context{
+		Config: conf,
+		Rng:    NewRand(conf.CSCTreeConfig.Seed),
+	}
+}
+
+func NewRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
 }
 
+// 读取配置文件，配置文件无法读取或配置不合法时返回错误
 func NewContext(iniPath string) (*Context, error) {
 	// 读取配置文件
-	conf := config.NewServerConfig(iniPath)
+	conf, err := config.NewServerConfig(iniPath)
+	if err != nil {
+		return nil, err
+	}
 
 	// 返回 Context 实例
-	return &Context{
-		Config: conf,
-	}, nil
+	return newContext(conf), nil
 }
 
 func NewContextOnlyConfig(iniPath string) (*Context, error) {
 	// 读取配置文件
-	conf := config.NewServerConfig(iniPath)
+	conf, err := config.NewServerConfig(iniPath)
+	if err != nil {
+		return nil, err
+	}
 
 	// 返回 Context 实例
-	return &Context{
-		Config: conf,
-	}, nil
+	return newContext(conf), nil
+}
+
+// 读取 INI 文件中的 [CSCTree] 以及 [CSCTree.<profile>]
+func NewContextWithProfile(iniPath string, profile string) (*Context, error) {
+	conf, err := config.NewServerConfigWithProfile(iniPath, profile)
+	if err != nil {
+		return nil, err
+	}
+	return newContext(conf), nil
+}
+
+// 直接使用代码中构造的配置，配置不合法时返回错误
+func NewContextWithConfig(cscTreeConfig *config.CSCTreeConfig) (*Context, error) {
+	if err := cscTreeConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return newContext(&config.ServerConfig{
+		CSCTreeConfig: cscTreeConfig,
+	}), nil
 }
@@ -0,0 +1,56 @@
+package context
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewContext(t *testing.T) {
+	ctx, err := NewContext("../config.ini")
+	assert.Nil(t, err)
+	assert.Equal(t, config.DefaultCSCTreeConfig(), ctx.Config.CSCTreeConfig)
+
+	_, err = NewContext("missing.ini")
+	assert.NotNil(t, err)
+
+	c := config.DefaultCSCTreeConfig()
+	c.UseFlatten = true
+	ctx, err = NewContextWithConfig(c)
+	assert.Nil(t, err)
+	assert.Same(t, c, ctx.Config.CSCTreeConfig)
+
+	c.PartitionNum = 0
+	_, err = NewContextWithConfig(c)
+	assert.ErrorContains(t, err, "PartitionNum")
+}
+
+func TestRand(t *testing.T) {
+	c := config.DefaultCSCTreeConfig()
+	c.Seed = 7
+	ctx1, _ := NewContextWithConfig(c)
+	ctx2, _ := NewContextWithConfig(c)
+	assert.Equal(t, ctx1.Rand().Uint64(), ctx2.Rand().Uint64())
+	// 同一个 Context 共用一个随机数来源
+	assert.Same(t, ctx1.Rand(), ctx1.Rand())
+	assert.NotNil(t, ctx1.Rng)
+
+	// 直接构造的 Context 在并发的第一次调用中只创建一个随机数来源
+	ctx := &Context{Config: &config.ServerConfig{CSCTreeConfig: c}}
+	rngs := make([]*rand.Rand, 8)
+	var wg sync.WaitGroup
+	for i := range rngs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rngs[i] = ctx.Rand()
+		}(i)
+	}
+	wg.Wait()
+	for _, rng := range rngs {
+		assert.Same(t, rngs[0], rng)
+	}
+}
@@ -0,0 +1,42 @@
+package csctree
+
+import (
+	"strconv"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/context"
+	"github.com/liuys-dase/csc-tree/filter/cscsketch"
+)
+
+// 构建 CSCR 时发现的负载异常的 bucket
+type Anomaly struct {
+	Level     int               `json:"level"` // CSCR 所在节点的层级
+	Range     *block.BlockRange `json:"range"` // CSCR 覆盖的区块范围
+	Component string            `json:"component"`
+	*cscsketch.BucketAnomaly
+}
+
+// DetectAnomalies 开启时，检查刚构建完成的 CSCR 中每个 bucket 的负载，结果记录在 t.Anomalies 中
+func (t *CSCTree) detectAnomalies(ctx *context.Context, level int, rng *block.BlockRange, component string, cscr *cscsketch.CSCR, items []string, nids []int) {
+	if !ctx.Config.CSCTreeConfig.DetectAnomalies {
+		return
+	}
+	fileIds := make([]string, len(nids))
+	for i, nid := range nids {
+		fileIds[i] = strconv.Itoa(nid)
+	}
+	for _, a := range cscr.LoadAnomalies(items, fileIds) {
+		t.Anomalies = append(t.Anomalies, &Anomaly{Level: level, Range: rng, Component: component, BucketAnomaly: a})
+	}
+}
+
+// 将 account -> nid 展开为两个等长的切片
+func flattenPairs(kvs map[string]int) ([]string, []int) {
+	items := make([]string, 0, len(kvs))
+	nids := make([]int, 0, len(kvs))
+	for item, nid := range kvs {
+		items = append(items, item)
+		nids = append(nids, nid)
+	}
+	return items, nids
+}
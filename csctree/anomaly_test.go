@@ -0,0 +1,35 @@
+package csctree
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashKey(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		build := func(key string) (*CSCForest, map[string][]int) {
+			ctx := testContext(5, useFlatten)
+			ctx.Config.CSCTreeConfig.Seed = 42
+			ctx.Config.CSCTreeConfig.HashKey = key
+			ctx.Config.CSCTreeConfig.DetectAnomalies = true
+			return buildTestForest(ctx, 64, 1)
+		}
+		public, _ := build("")
+		keyed, truth := build("secret-key")
+		assert.NotEqual(t, sketchContents(public), sketchContents(keyed))
+		assert.NotEqual(t, public.CSCForest[0].HashGroup.Seeds, keyed.CSCForest[0].HashGroup.Seeds)
+		for account, blocks := range truth {
+			nodes, _ := keyed.Get(account)
+			assert.Subset(t, blockNumbers(nodes), blocks, account)
+		}
+
+		fs := keyed.ForestStats()
+		// 随机的地址不会触发异常检测
+		assert.Equal(t, 0, fs.Anomalies)
+		assert.False(t, strings.Contains(fs.JSON(), "secret-key"))
+		assert.Equal(t, "REDACTED", fs.Trees[0].Config.HashKey)
+		assert.Equal(t, "secret-key", keyed.Context.Config.CSCTreeConfig.HashKey)
+	}
+}
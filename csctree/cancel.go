@@ -0,0 +1,95 @@
+package csctree
+
+import (
+	stdcontext "context"
+	"time"
+
+	"github.com/liuys-dase/csc-tree/block"
+)
+
+// 广度优先遍历，每次从 Deque 中取出 QueryPlan 前检查 ctx，被取消或超时时返回已经找到的结果以及 ctx.Err()。
+// 返回的叶子节点包装为 *QueryResult，记录来源以及误报概率
+func (t *CSCTree) search(ctx stdcontext.Context, item string, rng *block.BlockRange) ([]Node, *QueryStats, error) {
+	start_time := time.Now()
+	res := make([]Node, 0)
+	if t.IsEmpty() {
+		return res, NewQueryStats(), nil
+	}
+	tr := t.newTraversal(item, rng)
+	defer func() { tr.stats.TotalTime = time.Since(start_time) }()
+	queue := NewDeque()
+	tr.push = func(qp QueryPlan) { queue.PushBack(qp) }
+	tr.emit = func(n Node) {}
+	tr.record = func(r QueryResult) { res = append(res, &r) }
+	queue.PushBack(NewQueryPlan(t.Root, nil, false, false))
+	for queue.Size() > 0 {
+		if err := ctx.Err(); err != nil {
+			return res, tr.stats, err
+		}
+		tr.expand(queue.RemoveFromFront().(QueryPlan))
+	}
+	return res, tr.stats, nil
+}
+
+// 可取消的 Get
+func (t *CSCTree) GetWithContext(ctx stdcontext.Context, item string) ([]Node, *QueryStats, error) {
+	return t.search(ctx, item, nil)
+}
+
+// 可取消的 GetWithRange
+func (t *CSCTree) GetWithRangeWithContext(ctx stdcontext.Context, item string, start_block int, end_block int) ([]Node, *QueryStats, error) {
+	return t.search(ctx, item, block.NewBlockRange(start_block, end_block))
+}
+
+// 可取消的 GetWithKLeafs
+func (t *CSCTree) GetWithKLeafsWithContext(ctx stdcontext.Context, item string) ([]Node, *QueryStats, error) {
+	return t.search(ctx, item, nil)
+}
+
+// 可取消的 Get，出错时返回已经查询完成的 CSCTree 的结果以及当前 CSCTree 的部分结果
+func (cscForest *CSCForest) GetWithContext(ctx stdcontext.Context, item string) ([]Node, *QueryStats, error) {
+	return cscForest.searchAll("Get", func(t *CSCTree) ([]Node, *QueryStats, error) {
+		if !cscForest.Context.Config.CSCTreeConfig.UseFlatten {
+			return t.GetWithContext(ctx, item)
+		}
+		return t.GetWithKLeafsWithContext(ctx, item)
+	})
+}
+
+// 可取消的 GetWithRange
+func (cscForest *CSCForest) GetWithRangeWithContext(ctx stdcontext.Context, item string, start_block int, end_block int) ([]Node, *QueryStats, error) {
+	// 遍历同时处理 FlattenNode，两种模式都按范围裁剪
+	return cscForest.searchAll("GetWithRange", func(t *CSCTree) ([]Node, *QueryStats, error) {
+		return t.GetWithRangeWithContext(ctx, item, start_block, end_block)
+	})
+}
+
+// 可取消的 GetWithKLeafs
+func (cscForest *CSCForest) GetWithKLeafsWithContext(ctx stdcontext.Context, item string) ([]Node, *QueryStats, error) {
+	return cscForest.searchAll("GetWithKLeafs", func(t *CSCTree) ([]Node, *QueryStats, error) {
+		return t.GetWithKLeafsWithContext(ctx, item)
+	})
+}
+
+// 依次查询每棵 CSCTree，合并统计信息并记录到 cscForest.Stats 中
+func (cscForest *CSCForest) searchAll(op string, get func(t *CSCTree) ([]Node, *QueryStats, error)) ([]Node, *QueryStats, error) {
+	start_time := time.Now()
+	nodes := make([]Node, 0)
+	stats := NewQueryStats()
+	defer func() {
+		stats.TotalTime = time.Since(start_time)
+		cscForest.Stats.Record(op, stats)
+	}()
+	for _, t := range cscForest.CSCForest {
+		if t.IsEmpty() {
+			continue
+		}
+		res, treeStats, err := get(t)
+		nodes = append(nodes, res...)
+		stats.Add(treeStats)
+		if err != nil {
+			return nodes, stats, err
+		}
+	}
+	return nodes, stats, nil
+}
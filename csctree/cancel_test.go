@@ -0,0 +1,48 @@
+package csctree
+
+import (
+	stdcontext "context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWithContext(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		forest, truth := buildTestForest(testContext(5, useFlatten), 96, 6)
+		for account := range truth {
+			// 未取消时与原有查询结果一致（CSCR 返回结果的顺序本身不固定，只比较区块）
+			expected, _ := forest.Get(account)
+			nodes, _, err := forest.GetWithContext(stdcontext.Background(), account)
+			assert.Nil(t, err)
+			assert.Equal(t, blockNumbers(expected), blockNumbers(nodes), account)
+			expected, _ = forest.GetWithRange(account, 20, 60)
+			nodes, _, err = forest.GetWithRangeWithContext(stdcontext.Background(), account, 20, 60)
+			assert.Nil(t, err)
+			assert.Equal(t, blockNumbers(expected), blockNumbers(nodes), account)
+			// FlattenNode 中的叶子同样按范围裁剪
+			for _, b := range blockNumbers(nodes) {
+				assert.True(t, b >= 20 && b <= 60, "%s: %d", account, b)
+			}
+			for _, b := range truth[account] {
+				if b >= 20 && b <= 60 {
+					assert.Contains(t, blockNumbers(nodes), b, account)
+				}
+			}
+		}
+	}
+
+	forest, _ := buildTestForest(testContext(5, false), 96, 6)
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	nodes, _, err := forest.GetWithContext(ctx, "0x0000")
+	assert.Equal(t, stdcontext.Canceled, err)
+	assert.Empty(t, nodes)
+
+	ctx, cancel = stdcontext.WithTimeout(stdcontext.Background(), time.Nanosecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+	_, _, err = forest.GetWithRangeWithContext(ctx, "0x0000", 0, 95)
+	assert.Equal(t, stdcontext.DeadlineExceeded, err)
+}
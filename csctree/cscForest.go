@@ -1,8 +1,10 @@
 package csctree
 
 import (
+	stdcontext "context"
 	"math"
 	"sync"
+	"time"
 
 	"github.com/liuys-dase/csc-tree/context"
 )
@@ -11,15 +13,47 @@ type CSCForest struct {
 	CSCForest []*CSCTree // CSCTree 数组
 	Current   int        // 当前写入的 CSCTree 的索引
 	Context   *context.Context
+	Role      Role             // 索引的账户角色，森林中所有 CSCTree 保持一致
+	Stats     *StatsAggregator // 所有查询的累计统计信息
+	// 内存上限（字节），0 表示不限制；预计的内存占用接近上限时，新的 CSCTree 使用更节省内存的配置
+	MemoryLimit int
+	usage       int // 已经构建完成的 CSCTree 的内存占用
+	lastUsage   int // 最近一棵构建完成的 CSCTree 的内存占用，用于预测下一棵 CSCTree
 }
 
 func NewCSCForest(context *context.Context) *CSCForest {
-	return &CSCForest{
-		// 创建一个 CSCTree 实例
-		CSCForest: []*CSCTree{NewCSCTree(context)},
-		Current:   0,
-		Context:   context,
+	return NewCSCForestWithRole(context, SENDER)
+}
+
+// 创建一个按指定账户角色（发送方或接收方）建立索引的 CSCForest
+func NewCSCForestWithRole(context *context.Context, role Role) *CSCForest {
+	cscForest := &CSCForest{
+		Current: 0,
+		Context: context,
+		Role:    role,
+		Stats:   NewStatsAggregator(),
+	}
+	// 创建一个 CSCTree 实例
+	cscForest.CSCForest = []*CSCTree{cscForest.newTree()}
+	return cscForest
+}
+
+func (cscForest *CSCForest) newTree() *CSCTree {
+	ctx := cscForest.Context
+	level := 0
+	if len(cscForest.CSCForest) > 0 {
+		level = cscForest.CSCForest[len(cscForest.CSCForest)-1].DegradeLevel
+		if cscForest.nearMemoryLimit() {
+			level++
+		}
+	}
+	if level > 0 {
+		ctx = degradedContext(cscForest.Context, level)
 	}
+	t := NewCSCTree(ctx)
+	t.Role = cscForest.Role
+	t.DegradeLevel = level
+	return t
 }
 
 func (cscForest *CSCForest) AddwithBlock(blockNumber int, txnStrings []string) {
@@ -27,77 +61,49 @@ func (cscForest *CSCForest) AddwithBlock(blockNumber int, txnStrings []string) {
 	currentCSCTree := cscForest.CSCForest[cscForest.Current]
 	// MODIFY
 	if !cscForest.Context.Config.CSCTreeConfig.UseFlatten {
-		currentCSCTree.AddWithBlock(blockNumber, txnStrings, cscForest.Context)
+		currentCSCTree.AddWithBlock(blockNumber, txnStrings, currentCSCTree.Context)
 	} else {
-		currentCSCTree.AddWithBlockWithKLeafs(blockNumber, txnStrings, cscForest.Context)
+		currentCSCTree.AddWithBlockWithKLeafs(blockNumber, txnStrings, currentCSCTree.Context)
 	}
 	// currentCSCTree.AddWithBlock(blockNumber, txnStrings, cscForest.Context)
 	// currentCSCTree.AddWithBlockWithKLeafs(blockNumber, txnStrings, cscForest.Context)
 	// 每次添加一个 LeafNode 后，判断当前 CSCTree 是否已满
 	if currentCSCTree.Full() {
+		cscForest.lastUsage = currentCSCTree.MemoryUsage()
+		cscForest.usage += cscForest.lastUsage
 		// 如果当前 CSCTree 已满，则创建一个新的 CSCTree
-		cscForest.CSCForest = append(cscForest.CSCForest, NewCSCTree(cscForest.Context))
+		cscForest.CSCForest = append(cscForest.CSCForest, cscForest.newTree())
 		cscForest.Current++
 	}
 }
 
-func (cscForest *CSCForest) Get(item string) ([]Node, int64, int) {
-	nodes := make([]Node, 0)
-	cscrTime := int64(0)
-	cscrCount := 0
-	for _, t := range cscForest.CSCForest {
-		if !t.IsEmpty() {
-			// MODIFY
-			if !cscForest.Context.Config.CSCTreeConfig.UseFlatten {
-				nodes = append(nodes, t.Get(item)...)
-				cscrTime += t.TimeCounter.GetCSCRTime()
-				cscrCount++
-				t.TimeCounter.Clear()
-			} else {
-				nodes = append(nodes, t.GetWithKLeafs(item)...)
-				cscrTime += t.TimeCounter.GetCSCRTime()
-				cscrCount++
-				t.TimeCounter.Clear()
-			}
-		}
-	}
-	return nodes, cscrTime, cscrCount
+func (cscForest *CSCForest) Get(item string) ([]Node, *QueryStats) {
+	nodes, stats, _ := cscForest.GetWithContext(stdcontext.Background(), item)
+	return nodes, stats
 }
 
-func (cscForest *CSCForest) GetWithRange(item string, start_block int, end_block int) ([]Node, int64, int) {
-	nodes := make([]Node, 0)
-	cscrTime := int64(0)
-	cscrCount := 0
-	for _, t := range cscForest.CSCForest {
-		if !t.IsEmpty() {
-			// MODIFY
-			if !cscForest.Context.Config.CSCTreeConfig.UseFlatten {
-				nodes = append(nodes, t.GetWithRange(item, start_block, end_block)...)
-				cscrTime += t.TimeCounter.GetCSCRTime()
-				cscrCount++
-				t.TimeCounter.Clear()
-			} else {
-				nodes = append(nodes, t.GetWithKLeafs(item)...)
-				cscrTime += t.TimeCounter.GetCSCRTime()
-				cscrCount++
-				t.TimeCounter.Clear()
-			}
-		}
-	}
-	return nodes, cscrTime, cscrCount
+func (cscForest *CSCForest) GetWithRange(item string, start_block int, end_block int) ([]Node, *QueryStats) {
+	nodes, stats, _ := cscForest.GetWithRangeWithContext(stdcontext.Background(), item, start_block, end_block)
+	return nodes, stats
 }
 
 // Get 方法使用多线程执行
-func (cscForest *CSCForest) GetMultiThread(item string) []Node {
+func (cscForest *CSCForest) GetMultiThread(item string) ([]Node, *QueryStats) {
+	start_time := time.Now()
+	type treeResult struct {
+		nodes []Node
+		stats *QueryStats
+	}
 	var wg sync.WaitGroup
-	nodeChannel := make(chan []Node, len(cscForest.CSCForest))
+	resultChannel := make(chan treeResult, len(cscForest.CSCForest))
 
 	for _, t := range cscForest.CSCForest {
 		if !t.IsEmpty() {
 			wg.Add(1)
 			go func(tree *CSCTree) {
 				defer wg.Done()
-				nodeChannel <- tree.Get(item)
+				nodes, stats := tree.Get(item)
+				resultChannel <- treeResult{nodes, stats}
 			}(t)
 		}
 	}
@@ -105,16 +111,19 @@ func (cscForest *CSCForest) GetMultiThread(item string) []Node {
 	// 等待所有 Goroutine 完成
 	go func() {
 		wg.Wait()
-		close(nodeChannel)
+		close(resultChannel)
 	}()
 
 	// 收集结果
 	nodes := make([]Node, 0)
-	for n := range nodeChannel {
-		nodes = append(nodes, n...)
+	stats := NewQueryStats()
+	for r := range resultChannel {
+		nodes = append(nodes, r.nodes...)
+		stats.Add(r.stats)
 	}
-
-	return nodes
+	stats.TotalTime = time.Since(start_time)
+	cscForest.Stats.Record("GetMultiThread", stats)
+	return nodes, stats
 }
 
 func (cscForest *CSCForest) GetBitSize() int {
@@ -8,19 +8,39 @@ package csctree
 */
 
 import (
+	stdcontext "context"
+	"fmt"
 	"math"
 	"strconv"
-	"time"
 
 	"github.com/liuys-dase/csc-tree/block"
 	"github.com/liuys-dase/csc-tree/context"
 	"github.com/liuys-dase/csc-tree/filter/basicfilter"
 	"github.com/liuys-dase/csc-tree/filter/cscsketch"
-	"github.com/liuys-dase/csc-tree/timecounter"
 )
 
+// 建立索引时使用的账户角色
+type Role int
+
+const (
+	SENDER   Role = iota + 1 // 按交易的发送方建立索引
+	RECEIVER                 // 按交易的接收方建立索引
+)
+
+func (r Role) String() string {
+	switch r {
+	case SENDER:
+		return "sender"
+	case RECEIVER:
+		return "receiver"
+	default:
+		return "unknown"
+	}
+}
+
 type CSCTree struct {
 	Root         Node
+	Role         Role
 	queue        *Deque
 	MaxLevel     int
 	GlobalNid    int
@@ -28,7 +48,10 @@ type CSCTree struct {
 	NodeIndex    map[int]Node
 	HashGroup    *basicfilter.BFHashGroup
 	CscCacheList *cscsketch.CSCCacheList
-	TimeCounter  *timecounter.BlockSketchTimeCounter
+	Context      *context.Context // 构建该 CSCTree 时使用的配置
+	DegradeLevel int              // 因内存限制而降级的次数，0 表示使用原始配置
+	Anomalies    []*Anomaly       // 构建时发现的负载异常的 bucket，需要开启 DetectAnomalies
+	filters      []string         // 每一层节点使用的过滤器类型，创建 CSCTree 时解析一次
 }
 
 func NewCSCTree(context *context.Context) *CSCTree {
@@ -39,113 +62,32 @@ func NewCSCTree(context *context.Context) *CSCTree {
 		nodeIndex = make(map[int]Node)
 	}
 	// hash func
-	hashGroup := basicfilter.NewBFHashGroup(context.Config.CSCTreeConfig.BfHashFuncNum)
+	// HashKey 为空时使用公开的种子
+	hashKey := context.Config.CSCTreeConfig.HashKey
+	hashGroup := basicfilter.NewBFHashGroupWithKey(context.Config.CSCTreeConfig.BfHashFuncNum, hashKey)
+	// 通过 NewContext* 创建的配置已经校验过，这里只可能遇到代码中构造的不合法配置
+	filters, err := context.Config.CSCTreeConfig.LevelFilterTypes()
+	if err != nil {
+		panic(fmt.Sprintf("invalid node filter config: %v", err))
+	}
 	return &CSCTree{
+		Role:         SENDER,
 		queue:        NewDeque(),
 		MaxLevel:     context.Config.CSCTreeConfig.MaxLevel,
 		GlobalNid:    1,
 		UseNodeIndex: useNodeIndex,
 		NodeIndex:    nodeIndex,
 		HashGroup:    hashGroup,
-		CscCacheList: cscsketch.NewCSCCacheList(context.Config.CSCTreeConfig.RepetitionNum),
-		TimeCounter:  timecounter.NewBlockSketchTimeCounter(),
+		CscCacheList: cscsketch.NewCSCCacheListWithKey(context.Config.CSCTreeConfig.RepetitionNum, context.Rand(), hashKey),
+		Context:      context,
+		filters:      filters,
 	}
 }
 
 // 查找一个 item 所在的全部叶子节点
-func (t *CSCTree) Get(item string) []Node {
-	// 清除缓存
-	t.CscCacheList.Clear()
-	res := make([]Node, 0)
-	if t.IsEmpty() {
-		return res
-	}
-	queue := NewDeque()
-	queue.PushBack(NewQueryPlan(t.Root, nil, false, false))
-	for queue.Size() > 0 {
-		qp := queue.RemoveFromFront().(QueryPlan)
-		node := qp.N
-		switch n := node.(type) {
-		// 如果是 RootNode，则将左孩子加入队列（只需要加入一个节点，另一个可以通过 sibling 指针获取）
-		case *RootNode:
-			queue.PushBack(NewQueryPlan(n.LeftChild, nil, false, false))
-		case *InternalNode:
-			// 如果是由 cscr 推入的中间节点，则无需检查 BloomFilter，直接将左孩子加入队列
-			start_time := time.Now()
-			hit := n.BloomFilter.GetWithHashGroup(item, t.HashGroup)
-			t.TimeCounter.AddBFTime(start_time)
-			if hit && !qp.IgnoreBfCheck {
-				// 先检查是否在 BloomFilter 中，如果在，则将左右孩子加入队列
-				// 将左孩子和兄弟节点的左孩子加入队列
-				queue.PushBack(NewQueryPlan(n.LeftChild, n, true, false))
-				siblingLeftChild := n.GetSiblingNode().GetLeftChild()
-				queue.PushBack(NewQueryPlan(siblingLeftChild, n, true, false))
-			} else {
-				// 如果不在 BloomFilter 中，则需要进一步检查 CSCR
-				// cscr_res := n.CSCR.Get(item)
-				start_time := time.Now()
-				cscr_res := n.CSCR.GetWithCache(item, t.CscCacheList)
-				t.TimeCounter.AddCSCRTime(start_time)
-				if len(cscr_res) == 0 && qp.IsPushedByBf {
-					// 如果是由 BloomFilter 推入的节点，且 CSCR 为空，说明布隆过滤器假阳了，需要回溯检查上一层的 csc
-					queue.PushBack(NewQueryPlan(qp.ParentNode, nil, false, true))
-					continue
-				}
-				for _, nodeId := range cscr_res {
-					nid, _ := strconv.Atoi(nodeId)
-					// 向下遍历树，找到符合条件的节点
-					foundNode := t.findNodeById(n, nid)
-					if foundNode != nil {
-						// 叶子节点直接返回
-						if foundNode.GetNodeType() == LEAF {
-							res = append(res, foundNode)
-						} else {
-							// 将节点加入队列（不会有 node == nil 的情况）
-							queue.PushBack(NewQueryPlan(foundNode.GetLeftChild(), node, false, false))
-							// cscr 如果返回的结果是中间节点，可以判断，如果是叶子节点，则将左孩子加入队列；
-							// 如果是中间节点，则将左右孩子的左孩子分别加入队列
-							// 虽然这种方式可以跳过一些 bf 的检查，但是可能会增加要检查的点的数量，从而导致最后的查询时间增加
-							// if foundNode.GetLeftChild().GetNodeType() == LEAF {
-							// 	queue.PushBack(NewQueryPlan(foundNode.GetLeftChild(), foundNode, false, false))
-							// } else {
-							// 	queue.PushBack(NewQueryPlan(foundNode.GetLeftChild().GetLeftChild(), foundNode, false, false))
-							// 	queue.PushBack(NewQueryPlan(foundNode.GetRightChild().GetLeftChild(), foundNode, false, false))
-							// }
-						}
-					}
-				}
-			}
-		case *LeafNode:
-			// 先检查是否在 BloomFilter 中，如果在，则将其与兄弟节点加入 res
-			start_time := time.Now()
-			hit := n.BloomFilter.GetWithHashGroup(item, t.HashGroup)
-			t.TimeCounter.AddBFTime(start_time)
-			if hit {
-				res = append(res, n, n.GetSiblingNode())
-			} else {
-				// 如果不在 BloomFilter 中，则需要进一步检查 CSCR
-				// cscr_res := n.CSCR.Get(item)
-				start_time := time.Now()
-				cscr_res := n.CSCR.GetWithCache(item, t.CscCacheList)
-				t.TimeCounter.AddCSCRTime(start_time)
-				if len(cscr_res) == 0 && qp.IsPushedByBf {
-					// 如果是由 BloomFilter 推入的节点，且 CSCR 为空，则说明父节点的 bf 假阳了
-					queue.PushBack(NewQueryPlan(qp.ParentNode, nil, false, true))
-					continue
-				}
-				for _, nodeId := range cscr_res {
-					// 由于已经是叶子节点，所以不需要向下遍历
-					nid, _ := strconv.Atoi(nodeId)
-					if n.GetNid() == nid {
-						res = append(res, n)
-					} else {
-						res = append(res, n.GetSiblingNode())
-					}
-				}
-			}
-		}
-	}
-	return res
+func (t *CSCTree) Get(item string) ([]Node, *QueryStats) {
+	res, stats, _ := t.GetWithContext(stdcontext.Background(), item)
+	return res, stats
 }
 
 // 添加叶子节点
@@ -206,7 +148,7 @@ func (t *CSCTree) Add(l *LeafNode, ctx *context.Context) bool {
 func (t *CSCTree) AddWithBlock(blockNumber int, txns []string, ctx *context.Context) bool {
 	leafNode := NewLeafNode(blockNumber)
 	leafNode.SetNid(t.GlobalNid)
-	leafNode.SetSenderSet(block.NewAccountSetFromBlock(blockNumber, txns, true, leafNode.Nid))
+	leafNode.SetSenderSet(block.NewAccountSetFromBlock(blockNumber, txns, t.Role == SENDER, leafNode.Nid))
 	t.updateIndex(leafNode)
 	t.GlobalNid++
 	return t.Add(leafNode, ctx)
@@ -220,19 +162,28 @@ func (t *CSCTree) InitializeCSCR(node Node, intersection []string, ctx *context.
 	senderSet := node.GetSenderSet().BatchGetWithDelete(intersection)
 	// 这里根据 node 的 level 判断是否直接用 hashmap 还是 cscr
 	if node.GetLevel()-1 < ctx.Config.CSCTreeConfig.SketchLevel {
-		cscr := cscsketch.NewCSCRWithHashMap()
-		for v, nodeId := range senderSet {
-			cscr.Add(v, strconv.Itoa(nodeId))
-			// cscr.Add(v, nodeId)
-		}
-		return cscr
+		return newHashMapCSCR(senderSet)
 	} else {
 		cscr := t.NewCSCRWithEstimation(len(senderSet), ctx, node.GetRange().Size())
-		cscr.BatchAdd(senderSet)
+		if err := cscr.BatchAdd(senderSet); err != nil {
+			// 扩容达到上限仍然放不下时改用 HashMap，避免漏报
+			return newHashMapCSCR(senderSet)
+		}
+		items, nids := flattenPairs(senderSet)
+		t.detectAnomalies(ctx, node.GetLevel()-1, node.GetRange(), COMPONENT_CSCR, cscr, items, nids)
 		return cscr
 	}
 }
 
+// 用 HashMap 精确保存 account -> nid
+func newHashMapCSCR(kvs map[string]int) *cscsketch.CSCR {
+	cscr := cscsketch.NewCSCRWithHashMap()
+	for v, nodeId := range kvs {
+		cscr.Add(v, strconv.Itoa(nodeId))
+	}
+	return cscr
+}
+
 // 由 CSCTree 调用，生成新的 InternalNode（InternalNode.NewInternalNode仅仅负责生成新的节点，其他的处理逻辑放在这边，避免 Node 内部需要存储 context）
 func (t *CSCTree) CreateInternalNode(leftNode Node, rightNode Node, ctx *context.Context) *InternalNode {
 	// log.Printf("CreateInternalNode: %v, %v\n", leftNode.GetNid(), rightNode.GetNid())
@@ -247,11 +198,11 @@ func (t *CSCTree) CreateInternalNode(leftNode Node, rightNode Node, ctx *context
 
 	// log.Printf("intersection: %v\n", intersection)
 
-	// step2. 将交集加入 children 的 bf
-	bf := t.NewBloomFilter(len(intersection), ctx)
+	// step2. 将交集加入 children 的过滤器
+	bf := t.NewFilter(len(intersection), leftNode.GetLevel(), ctx)
 	bf.BatchAdd(intersection)
-	leftNode.SetBloomFilter(bf)
-	rightNode.SetBloomFilter(bf)
+	leftNode.SetFilter(bf)
+	rightNode.SetFilter(bf)
 
 	// step3. 判断 leftNode 和 rightNode 的类型，如果是 InternalNode，则需要将交集加入他们的children 的 cscr；如果是 LeafNode，则不需要
 	if leftNode.GetNodeType() == INTERNAL {
@@ -294,8 +245,12 @@ func (t *CSCTree) CreateRootNode(internalNode *InternalNode, ctx *context.Contex
 	// 	fmt.Printf("%v,%v\n", v, nodeId)
 	// }
 
-	// 添加元素
-	cscr.BatchAdd(senderSet.GetAccount())
+	// 添加元素，放不下时改用 HashMap
+	if err := cscr.BatchAdd(senderSet.GetAccount()); err != nil {
+		cscr = newHashMapCSCR(senderSet.GetAccount())
+	}
+	items, nids := flattenPairs(senderSet.GetAccount())
+	t.detectAnomalies(ctx, internalNode.GetLevel()-1, internalNode.GetRange(), COMPONENT_CSCR, cscr, items, nids)
 
 	// 将 CSCR 加入两个孩子节点
 	internalNode.GetLeftChild().SetCSCR(cscr)
@@ -307,11 +262,21 @@ func (t *CSCTree) CreateRootNode(internalNode *InternalNode, ctx *context.Contex
 	return rootNode
 }
 
-func (t *CSCTree) NewBloomFilter(elementNum int, ctx *context.Context) *basicfilter.BloomFilter {
-	return basicfilter.NewBloomFilterWithHashGroup(elementNum,
-		ctx.Config.CSCTreeConfig.BfFalsePositiveRate,
-		ctx.Config.CSCTreeConfig.BfHashFuncNum,
-		t.HashGroup)
+// 创建第 level 层节点的过滤器，类型由创建 CSCTree 时解析的 LevelFilterTypes 决定
+func (t *CSCTree) NewFilter(elementNum int, level int, ctx *context.Context) basicfilter.MembershipFilter {
+	conf := ctx.Config.CSCTreeConfig
+	opts := &basicfilter.FilterOptions{
+		Fpr:         conf.BfFalsePositiveRate,
+		HashFuncNum: conf.BfHashFuncNum,
+		HashGroup:   t.HashGroup,
+		Rng:         ctx.Rand(),
+	}
+	f, err := basicfilter.NewMembershipFilter(t.filters[level], elementNum, opts)
+	if err != nil {
+		// LevelFilterTypes 只返回已注册的类型
+		panic(err)
+	}
+	return f
 }
 
 // 需要修改
@@ -327,7 +292,12 @@ func (t *CSCTree) NewCSCRWithEstimation(elementNum int, ctx *context.Context, bl
 	}
 	repetitionNum := ctx.Config.CSCTreeConfig.RepetitionNum
 	// return cscsketch.NewCSCRWithEstimation(elementNum, fingerprintSize, fingerprintNum, maxKickAttempts, partitionNum, repetitionNum)
-	return cscsketch.NewCSCRWithEstimationWithCache(elementNum, fingerprintSize, fingerprintNum, maxKickAttempts, partitionNum, repetitionNum, t.CscCacheList)
+	cscr := cscsketch.NewCSCRWithEstimationWithCache(elementNum, fingerprintSize, fingerprintNum, maxKickAttempts, partitionNum, repetitionNum, t.CscCacheList)
+	if ctx.Config.CSCTreeConfig.RefCount {
+		// 新建的 CSCR 为空，不会返回 ErrNotEmpty
+		cscr.EnableRefCount()
+	}
+	return cscr
 }
 
 // 从 RootNode 开始 BFS 遍历，返回 []Node
@@ -353,7 +323,7 @@ func (t *CSCTree) BFS() []Node {
 	return nodes
 }
 
-// 计算 csctree 中所有 csc 和 bf 的 bit size
+// 计算 csctree 中所有 csc 和过滤器的 bit size
 func (t *CSCTree) GetBitSize() int {
 	nodes := t.BFS()
 	total_bit_size := 0
@@ -361,11 +331,11 @@ func (t *CSCTree) GetBitSize() int {
 		switch n := node.(type) {
 		case *InternalNode:
 			// log.Printf("	InternalNode: %v, bit size of cscr: %v, ur: %v, count: %v", n.GetRange(), n.CSCR.GetBitSize(), n.CSCR.GetUtilizationRate(), n.CSCR.GetUtilizationCount())
-			total_bit_size += n.BloomFilter.GetBitSize()
+			total_bit_size += n.Filter.GetBitSize()
 			total_bit_size += n.CSCR.GetBitSize()
 		case *LeafNode:
 			// log.Printf("	InternalNode: %v, bit size of cscr: %v, ur: %v, count: %v", n.GetRange(), n.CSCR.GetBitSize(), n.CSCR.GetUtilizationRate(), n.CSCR.GetUtilizationCount())
-			total_bit_size += n.BloomFilter.GetBitSize()
+			total_bit_size += n.Filter.GetBitSize()
 			total_bit_size += n.CSCR.GetBitSize()
 		}
 	}
@@ -400,6 +370,9 @@ type QueryPlan struct {
 	ParentNode    Node
 	IsPushedByBf  bool
 	IgnoreBfCheck bool
+	// 到达该节点的路径上累积的误报概率，以及父节点对应的误报概率（回溯时使用）
+	fpProb       float64
+	parentFpProb float64
 }
 
 func NewQueryPlan(n Node, parentNode Node, isPushedByBf bool, ignoreBfCheck bool) QueryPlan {
@@ -472,116 +445,7 @@ func (t *CSCTree) updateIndex(node Node) {
 }
 
 // 查找一个 item 所在的全部叶子节点
-func (t *CSCTree) GetWithRange(item string, start_block int, end_block int) []Node {
-	block_range := block.NewBlockRange(start_block, end_block)
-	// 清除缓存
-	t.CscCacheList.Clear()
-	res := make([]Node, 0)
-	if t.IsEmpty() {
-		return res
-	}
-	queue := NewDeque()
-	queue.PushBack(NewQueryPlan(t.Root, nil, false, false))
-	for queue.Size() > 0 {
-		qp := queue.RemoveFromFront().(QueryPlan)
-		node := qp.N
-		switch n := node.(type) {
-		// 如果是 RootNode，则将左孩子加入队列（只需要加入一个节点，另一个可以通过 sibling 指针获取）
-		case *RootNode:
-			if n.GetRange().Intersect(block_range) {
-				queue.PushBack(NewQueryPlan(n.LeftChild, nil, false, false))
-			}
-		case *InternalNode:
-			// 如果当前结点的 range 和 block_range 没有重合，则没必要检查
-			if !n.GetRange().Intersect(block_range) && !n.GetSiblingNode().GetRange().Intersect(block_range) {
-				break
-			}
-			// 如果是由 cscr 推入的中间节点，则无需检查 BloomFilter，直接将左孩子加入队列
-			start_time := time.Now()
-			hit := n.BloomFilter.GetWithHashGroup(item, t.HashGroup)
-			t.TimeCounter.AddBFTime(start_time)
-			if hit && !qp.IgnoreBfCheck {
-				// 先检查是否在 BloomFilter 中，如果在，则将左右孩子加入队列
-				// 将左孩子和兄弟节点的左孩子加入队列
-				if n.LeftChild.GetRange().Intersect(block_range) || n.LeftChild.GetSiblingNode().GetRange().Intersect(block_range) {
-					queue.PushBack(NewQueryPlan(n.LeftChild, n, true, false))
-				}
-				siblingLeftChild := n.GetSiblingNode().GetLeftChild()
-				if siblingLeftChild.GetRange().Intersect(block_range) || siblingLeftChild.GetSiblingNode().GetRange().Intersect(block_range) {
-					queue.PushBack(NewQueryPlan(siblingLeftChild, n, true, false))
-				}
-			} else {
-				// 如果不在 BloomFilter 中，则需要进一步检查 CSCR
-				// cscr_res := n.CSCR.Get(item)
-				start_time := time.Now()
-				cscr_res := n.CSCR.GetWithCache(item, t.CscCacheList)
-				t.TimeCounter.AddCSCRTime(start_time)
-				if len(cscr_res) == 0 && qp.IsPushedByBf {
-					// 如果是由 BloomFilter 推入的节点，且 CSCR 为空，说明布隆过滤器假阳了，需要回溯检查上一层的 csc
-					queue.PushBack(NewQueryPlan(qp.ParentNode, nil, false, true))
-					continue
-				}
-				for _, nodeId := range cscr_res {
-					nid, _ := strconv.Atoi(nodeId)
-					// 向下遍历树，找到符合条件的节点
-					foundNode := t.findNodeById(n, nid)
-					if foundNode != nil {
-						// 叶子节点直接返回
-						if foundNode.GetNodeType() == LEAF {
-							if foundNode.GetRange().Intersect(block_range) {
-								res = append(res, foundNode)
-							}
-						} else {
-							// 将节点加入队列（不会有 node == nil 的情况）
-							if foundNode.GetLeftChild().GetRange().Intersect(block_range) || foundNode.GetLeftChild().GetSiblingNode().GetRange().Intersect(block_range) {
-								queue.PushBack(NewQueryPlan(foundNode.GetLeftChild(), node, false, false))
-							}
-						}
-					}
-				}
-			}
-		case *LeafNode:
-			// 如果当前结点的 range 和 block_range 没有重合，则没必要检查
-			if !n.GetRange().Intersect(block_range) && !n.GetSiblingNode().GetRange().Intersect(block_range) {
-				break
-			}
-			// 先检查是否在 BloomFilter 中，如果在，则将其与兄弟节点加入 res
-			start_time := time.Now()
-			hit := n.BloomFilter.GetWithHashGroup(item, t.HashGroup)
-			t.TimeCounter.AddBFTime(start_time)
-			if hit {
-				if n.GetRange().Intersect(block_range) {
-					res = append(res, n)
-				}
-				if n.GetSiblingNode().GetRange().Intersect(block_range) {
-					res = append(res, n.GetSiblingNode())
-				}
-			} else {
-				// 如果不在 BloomFilter 中，则需要进一步检查 CSCR
-				// cscr_res := n.CSCR.Get(item)
-				start_time := time.Now()
-				cscr_res := n.CSCR.GetWithCache(item, t.CscCacheList)
-				t.TimeCounter.AddCSCRTime(start_time)
-				if len(cscr_res) == 0 && qp.IsPushedByBf {
-					// 如果是由 BloomFilter 推入的节点，且 CSCR 为空，则说明父节点的 bf 假阳了
-					queue.PushBack(NewQueryPlan(qp.ParentNode, nil, false, true))
-					continue
-				}
-				for _, nodeId := range cscr_res {
-					// 由于已经是叶子节点，所以不需要向下遍历
-					nid, _ := strconv.Atoi(nodeId)
-					if n.GetNid() == nid {
-						if n.GetRange().Intersect(block_range) {
-							res = append(res, n)
-						}
-					} else {
-						if n.GetSiblingNode().GetRange().Intersect(block_range) {
-							res = append(res, n.GetSiblingNode())
-						}
-					}
-				}
-			}
-		}
-	}
-	return res
+func (t *CSCTree) GetWithRange(item string, start_block int, end_block int) ([]Node, *QueryStats) {
+	res, stats, _ := t.GetWithRangeWithContext(stdcontext.Background(), item, start_block, end_block)
+	return res, stats
 }
@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/liuys-dase/csc-tree/context"
+	"github.com/liuys-dase/csc-tree/filter/basicfilter"
+	"github.com/liuys-dase/csc-tree/filter/cscsketch"
+	"github.com/stretchr/testify/assert"
 )
 
 func cscTreeContext() *context.Context {
@@ -29,3 +32,139 @@ func TestCscTreeAdd(t *testing.T) {
 		fmt.Printf("%v\n", node)
 	}
 }
+
+// 收集 CSCTree 中所有 CSC 的种子与 bucket
+func sketchContents(f *CSCForest) []any {
+	res := make([]any, 0)
+	add := func(cscr *cscsketch.CSCR) {
+		if cscr == nil || cscr.CType != cscsketch.SKETCH {
+			return
+		}
+		for _, csc := range cscr.CSCs {
+			res = append(res, csc.SeedAnchor, csc.SeedOffset, csc.Buckets)
+		}
+	}
+	for _, t := range f.CSCForest {
+		for _, node := range t.BFS() {
+			add(cscrOf(node))
+			if n, ok := node.(*FlattenNode); ok {
+				add(n.FlattenCSCR)
+				for _, child := range n.Children {
+					add(child.CSCR)
+				}
+			}
+		}
+	}
+	return res
+}
+
+func TestSeededBuildIsReproducible(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		build := func(seed int64) (*CSCForest, map[string][]int) {
+			ctx := testContext(5, useFlatten)
+			ctx.Config.CSCTreeConfig.Seed = seed
+			return buildTestForest(ctx, 64, 1)
+		}
+		f1, truth := build(42)
+		f2, _ := build(42)
+		f3, _ := build(43)
+		assert.NotEmpty(t, sketchContents(f1))
+		assert.Equal(t, sketchContents(f1), sketchContents(f2))
+		assert.NotEqual(t, sketchContents(f1), sketchContents(f3))
+		assert.Equal(t, f1.ForestStats().JSON(), f2.ForestStats().JSON())
+		// 查询结果（包括误报以及返回的顺序）完全相同
+		for account := range truth {
+			for _, item := range []string{account, account + "_absent"} {
+				n1, _ := f1.Get(item)
+				n2, _ := f2.Get(item)
+				assert.Equal(t, nodeIds(n1), nodeIds(n2), item)
+			}
+		}
+	}
+}
+
+func nodeIds(nodes []Node) []int {
+	res := make([]int, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, n.GetNid())
+	}
+	return res
+}
+
+func TestLevelFilters(t *testing.T) {
+	for _, nodeFilter := range []string{basicfilter.FILTER_CUCKOO, basicfilter.FILTER_BINARY_FUSE, basicfilter.FILTER_BLOCKED_BLOOM} {
+		for _, useFlatten := range []bool{false, true} {
+			ctx := testContext(6, useFlatten)
+			ctx.Config.CSCTreeConfig.NodeFilter = nodeFilter
+			ctx.Config.CSCTreeConfig.LevelFilters = "4:BF"
+			// 固定种子，避免 CSCR 的误报导致结果随机漏报
+			ctx.Config.CSCTreeConfig.Seed = 1
+			assert.Nil(t, ctx.Config.CSCTreeConfig.Validate())
+			forest, truth := buildTestForest(ctx, 160, 1)
+			for account, blocks := range truth {
+				nodes, _ := forest.Get(account)
+				assert.Subset(t, blockNumbers(nodes), blocks, account)
+			}
+			fs := forest.ForestStats()
+			assert.True(t, fs.Total(nodeFilter).Structures > 0)
+			assert.True(t, fs.Total(basicfilter.FILTER_BLOOM).Structures > 0)
+			for _, ts := range fs.Trees {
+				for _, row := range ts.Components {
+					if row.Level == 4 {
+						assert.NotEqual(t, nodeFilter, row.Component)
+					}
+				}
+			}
+		}
+	}
+	// 未知的过滤器类型不会退回 BloomFilter
+	ctx := testContext(6, false)
+	ctx.Config.CSCTreeConfig.LevelFilters = "4:XF"
+	assert.Panics(t, func() { NewCSCTree(ctx) })
+}
+
+// 查询范围与节点边界不对齐时，BloomFilter 假阳的节点有一个孩子被范围裁剪，GetWithRange 仍然返回 Get 在范围内的所有结果
+func TestGetWithRangeUnaligned(t *testing.T) {
+	ctx := testContext(6, false)
+	ctx.Config.CSCTreeConfig.BfFalsePositiveRate = 0.3
+	forest, truth := buildTestForest(ctx, 128, 2)
+	for account := range truth {
+		all, _ := forest.Get(account)
+		for start := 0; start < 128; start += 5 {
+			for end := start; end < 128; end += 9 {
+				nodes, _ := forest.GetWithRange(account, start, end)
+				found := make(map[int]bool)
+				for _, n := range nodes {
+					found[n.GetRange().Start] = true
+				}
+				for _, b := range blockNumbers(all) {
+					if b >= start && b <= end {
+						assert.True(t, found[b], "%s %d [%d, %d]", account, b, start, end)
+					}
+				}
+			}
+		}
+	}
+}
+
+// 开启 RefCount 后节点的 CSCR 支持 Remove
+func TestRefCount(t *testing.T) {
+	ctx := testContext(5, false)
+	ctx.Config.CSCTreeConfig.RefCount = true
+	forest, _ := buildTestForest(ctx, 64, 1)
+	sketches := 0
+	for _, tree := range forest.CSCForest {
+		for _, node := range tree.BFS() {
+			cscr := cscrOf(node)
+			if cscr == nil || cscr.CType != cscsketch.SKETCH || cscr.IsEmpty() {
+				continue
+			}
+			sketches++
+			for _, csc := range cscr.CSCs {
+				assert.True(t, csc.RefCounted)
+			}
+			assert.ErrorIs(t, cscr.Remove("0xmissing", "0"), cscsketch.ErrNotFound)
+		}
+	}
+	assert.True(t, sketches > 0)
+}
@@ -0,0 +1,162 @@
+package csctree
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/liuys-dase/csc-tree/block"
+)
+
+type Order int
+
+const (
+	ASC Order = iota
+	DESC
+)
+
+const DefaultPageSize = 100
+
+type QueryOptions struct {
+	Order    Order
+	PageSize int               // 每页最多返回的叶子节点数，<= 0 时使用 DefaultPageSize
+	Range    *block.BlockRange // 为 nil 时查询全部区块
+}
+
+// 按区块顺序分页返回查询结果的游标，游标状态可以通过 Token 序列化，重启后使用 ResumeQuery 恢复
+type Cursor struct {
+	forest *CSCForest
+	state  cursorToken
+	walker *orderedWalker // 当前 CSCTree 上的遍历，为 nil 时需要切换到下一棵 CSCTree
+	done   bool
+	stats  *QueryStats // 当前页的统计信息
+}
+
+// 游标的序列化状态：Range 为剩余的查询范围，每返回一个叶子节点都会收缩；Tree 为当前 CSCTree 的下标
+type cursorToken struct {
+	Item     string            `json:"item"`
+	Order    Order             `json:"order"`
+	PageSize int               `json:"page_size"`
+	Range    *block.BlockRange `json:"range"`
+	Tree     int               `json:"tree"`
+}
+
+// 创建一个游标，结果在调用 Next 时才会惰性地计算
+func (cscForest *CSCForest) Query(item string, opts *QueryOptions) *Cursor {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+	state := cursorToken{
+		Item:     item,
+		Order:    opts.Order,
+		PageSize: opts.PageSize,
+		Range:    block.NewBlockRange(math.MinInt, math.MaxInt),
+		Tree:     0,
+	}
+	if state.PageSize <= 0 {
+		state.PageSize = DefaultPageSize
+	}
+	if opts.Range != nil {
+		state.Range = block.NewBlockRange(opts.Range.Start, opts.Range.End)
+	}
+	if state.Order == DESC {
+		state.Tree = len(cscForest.CSCForest) - 1
+	}
+	return &Cursor{forest: cscForest, state: state}
+}
+
+// 根据 Token 恢复游标
+func (cscForest *CSCForest) ResumeQuery(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor token: %w", err)
+	}
+	var state cursorToken
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid cursor token: %w", err)
+	}
+	if state.Range == nil || state.PageSize <= 0 || (state.Order != ASC && state.Order != DESC) {
+		return nil, fmt.Errorf("invalid cursor token: incomplete state")
+	}
+	c := &Cursor{forest: cscForest, state: state}
+	c.done = state.Range.Start > state.Range.End
+	return c, nil
+}
+
+// 序列化游标状态，只记录尚未返回的部分，因此恢复后不会重复返回已经返回过的叶子节点
+func (c *Cursor) Token() string {
+	data, _ := json.Marshal(c.state)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// 所有结果都已返回
+func (c *Cursor) Done() bool {
+	return c.done
+}
+
+// 返回下一页结果，最多 PageSize 个叶子节点，全部返回后结果为空
+func (c *Cursor) Next() ([]Node, *QueryStats) {
+	start_time := time.Now()
+	page := make([]Node, 0)
+	c.stats = NewQueryStats()
+	defer func() {
+		// 遍历时确认的叶子节点可能还留在堆中，只统计实际返回的数量
+		c.stats.LeavesReturned = len(page)
+		c.stats.TotalTime = time.Since(start_time)
+		c.forest.Stats.Record("Query", c.stats)
+	}()
+	if c.walker != nil {
+		// 两次调用之间可能有其他查询使用了同一棵 CSCTree 的缓存
+		c.walker.tr.t.CscCacheList.Clear()
+		c.walker.tr.stats = c.stats
+	}
+	for !c.done && len(page) < c.state.PageSize {
+		if c.walker == nil && !c.nextTree() {
+			c.done = true
+			break
+		}
+		n := c.walker.Next()
+		if n == nil {
+			c.walker = nil
+			if c.state.Order == ASC {
+				c.state.Tree++
+			} else {
+				c.state.Tree--
+			}
+			continue
+		}
+		page = append(page, n)
+		// 收缩剩余的查询范围
+		if c.state.Order == ASC {
+			c.state.Range.Start = n.GetRange().End + 1
+		} else {
+			c.state.Range.End = n.GetRange().Start - 1
+		}
+		if c.state.Range.Start > c.state.Range.End {
+			c.done = true
+		}
+	}
+	return page, c.stats
+}
+
+// 从当前下标开始找到下一棵与剩余查询范围有重合的 CSCTree
+func (c *Cursor) nextTree() bool {
+	trees := c.forest.CSCForest
+	for c.state.Tree >= 0 && c.state.Tree < len(trees) {
+		t := trees[c.state.Tree]
+		if !t.IsEmpty() && t.Root.GetRange().Intersect(c.state.Range) {
+			rng := block.NewBlockRange(c.state.Range.Start, c.state.Range.End)
+			c.walker = t.newOrderedWalker(c.state.Item, rng, c.state.Order == DESC)
+			c.walker.tr.stats = c.stats
+			return true
+		}
+		if c.state.Order == ASC {
+			c.state.Tree++
+		} else {
+			c.state.Tree--
+		}
+	}
+	return false
+}
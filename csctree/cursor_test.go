@@ -0,0 +1,106 @@
+package csctree
+
+import (
+	"testing"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/stretchr/testify/assert"
+)
+
+func collect(c *Cursor) []int {
+	res := make([]int, 0)
+	for {
+		page, _ := c.Next()
+		if len(page) == 0 {
+			break
+		}
+		for _, n := range page {
+			res = append(res, n.GetRange().Start)
+		}
+	}
+	return res
+}
+
+func TestCursor(t *testing.T) {
+	forest, truth := buildTestForest(testContext(5, false), 80, 5)
+	for account := range truth {
+		nodes, _ := forest.GetWithRange(account, 10, 70)
+		blocks := blockNumbers(nodes)
+		expected := make([]int, 0)
+		for i, b := range blocks {
+			if i == 0 || b != blocks[i-1] {
+				expected = append(expected, b)
+			}
+		}
+		opts := &QueryOptions{PageSize: 3, Range: block.NewBlockRange(10, 70)}
+		assert.Equal(t, expected, collect(forest.Query(account, opts)), account)
+
+		// 倒序
+		opts.Order = DESC
+		desc := collect(forest.Query(account, opts))
+		for i, j := 0, len(desc)-1; i < j; i, j = i+1, j-1 {
+			desc[i], desc[j] = desc[j], desc[i]
+		}
+		assert.Equal(t, expected, desc, account)
+
+		// 读取一页后通过 Token 恢复
+		opts.Order = ASC
+		c := forest.Query(account, opts)
+		first, stats := c.Next()
+		assert.Equal(t, len(first), stats.LeavesReturned)
+		assert.LessOrEqual(t, len(first), 3)
+		resumed, err := forest.ResumeQuery(c.Token())
+		assert.Nil(t, err)
+		res := make([]int, 0)
+		for _, n := range first {
+			res = append(res, n.GetRange().Start)
+		}
+		res = append(res, collect(resumed)...)
+		assert.Equal(t, expected, res, account)
+		assert.True(t, resumed.Done())
+	}
+	_, err := forest.ResumeQuery("not-a-token")
+	assert.NotNil(t, err)
+}
+
+// BloomFilter 误判率很高时回溯频繁，每一页之后通过 Token 恢复，结果仍然与 GetWithRange 一致
+func TestCursorHighFPR(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		ctx := testContext(6, useFlatten)
+		ctx.Config.CSCTreeConfig.BfFalsePositiveRate = 0.4
+		ctx.Config.CSCTreeConfig.Seed = 1
+		forest, truth := buildTestForest(ctx, 160, 1)
+		for account := range truth {
+			nodes, _ := forest.GetWithRange(account, 10, 150)
+			blocks := blockNumbers(nodes)
+			expected := make([]int, 0)
+			for i, b := range blocks {
+				if i == 0 || b != blocks[i-1] {
+					expected = append(expected, b)
+				}
+			}
+			for _, order := range []Order{ASC, DESC} {
+				c := forest.Query(account, &QueryOptions{Order: order, PageSize: 2, Range: block.NewBlockRange(10, 150)})
+				res := make([]int, 0)
+				for {
+					page, _ := c.Next()
+					if len(page) == 0 {
+						break
+					}
+					for _, n := range page {
+						res = append(res, n.GetRange().Start)
+					}
+					resumed, err := forest.ResumeQuery(c.Token())
+					assert.Nil(t, err)
+					c = resumed
+				}
+				if order == DESC {
+					for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+						res[i], res[j] = res[j], res[i]
+					}
+				}
+				assert.Equal(t, expected, res, account)
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package csctree
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/liuys-dase/csc-tree/block"
+)
+
+// 默认情况下，覆盖 2^4 个叶子节点及以上的子树命中后不再向下遍历
+const DefaultEstimateLevel = 4
+
+// 账户出现的区块数估计值，真实值落在 [Lower, Upper] 中：Lower 只计入精确确认的区块，过滤器误判只会使 Upper 偏大
+type BlockCountEstimate struct {
+	Estimate int
+	Lower    int
+	Upper    int
+}
+
+// 估计值与上下界的最大偏差
+func (e *BlockCountEstimate) ErrorBound() int {
+	return max(e.Estimate-e.Lower, e.Upper-e.Estimate)
+}
+
+func (e *BlockCountEstimate) Add(e2 *BlockCountEstimate) {
+	e.Estimate += e2.Estimate
+	e.Lower += e2.Lower
+	e.Upper += e2.Upper
+}
+
+func (e *BlockCountEstimate) String() string {
+	return fmt.Sprintf("%d [%d, %d]", e.Estimate, e.Lower, e.Upper)
+}
+
+type subtreeEstimate struct {
+	r *block.BlockRange
+	e *BlockCountEstimate
+}
+
+// 估计 item 在 [start_block, end_block] 中出现的区块数
+func (t *CSCTree) EstimateBlockCount(item string, start_block int, end_block int) (*BlockCountEstimate, *QueryStats) {
+	return t.EstimateBlockCountWithLevel(item, start_block, end_block, DefaultEstimateLevel)
+}
+
+// 估计 item 在 [start_block, end_block] 中出现的区块数。
+// 遍历过程与 GetWithRange 一致，但当一个层级不低于 level 的 QueryPlan 完全落在查询范围内时，不再向下遍历，而是直接按其覆盖的范围计数：
+//  1. 由 BloomFilter 推入：BloomFilter 可能误判，至少出现零次，最多出现在父节点覆盖的全部区块中
+//  2. 由 HashMap CSCR 推入：item 被提升到了该节点，说明两个孩子中都出现过，至少出现两次。Sketch CSCR 可能误判，下界为零
+//
+// 确认的叶子节点计入估计值与上界，只有 HashMap CSCR 确认的叶子节点计入下界。估计值取每个子树上下界的中点
+func (t *CSCTree) EstimateBlockCountWithLevel(item string, start_block int, end_block int, level int) (*BlockCountEstimate, *QueryStats) {
+	start_time := time.Now()
+	res := &BlockCountEstimate{}
+	if t.IsEmpty() {
+		return res, NewQueryStats()
+	}
+	block_range := block.NewBlockRange(start_block, end_block)
+	tr := t.newTraversal(item, block_range)
+	defer func() { tr.stats.TotalTime = time.Since(start_time) }()
+	queue := NewDeque()
+	leaves := make(map[int]bool) // 叶子节点是否由 HashMap CSCR 精确确认
+	// 按范围去重的子树。回溯时会推入与已记录的子树嵌套的范围，子树的范围只会嵌套或不相交，
+	// 因此被已记录的范围覆盖时只更新下界，覆盖已记录的范围时将其替换
+	subtrees := make([]*subtreeEstimate, 0)
+	tr.push = func(qp QueryPlan) {
+		r := planRange(qp)
+		// RootNode 推入的节点以及回溯的节点没有任何命中信息，需要继续遍历
+		if qp.ParentNode == nil || qp.IgnoreBfCheck || qp.N.GetLevel() < level || !within(block_range, r) {
+			queue.PushBack(qp)
+			return
+		}
+		// BloomFilter 以及 Sketch CSCR 都可能误判，只有 HashMap CSCR 确认的节点可以提高下界
+		lower := 0
+		if !qp.IsPushedByBf && cscrSource(cscrOf(qp.ParentNode)) == FROM_HASHMAP_CSCR {
+			lower = 2
+		}
+		for _, prev := range subtrees {
+			if within(prev.r, r) {
+				prev.e.Lower = max(prev.e.Lower, lower)
+				return
+			}
+		}
+		// 被覆盖的子树互不相交，下界可以相加
+		covered := 0
+		kept := subtrees[:0]
+		for _, prev := range subtrees {
+			if within(r, prev.r) {
+				covered += prev.e.Lower
+				continue
+			}
+			kept = append(kept, prev)
+		}
+		subtrees = append(kept, &subtreeEstimate{r: r, e: &BlockCountEstimate{Lower: min(max(lower, covered), r.Size()), Upper: r.Size()}})
+	}
+	tr.emit = func(n Node) {}
+	tr.record = func(r QueryResult) {
+		b := r.Node.GetRange().Start
+		leaves[b] = leaves[b] || r.Source == FROM_HASHMAP_CSCR
+	}
+	queue.PushBack(NewQueryPlan(t.Root, nil, false, false))
+	for queue.Size() > 0 {
+		tr.expand(queue.RemoveFromFront().(QueryPlan))
+	}
+	for _, sub := range subtrees {
+		// 子树中已经找到的叶子节点不再重复计数
+		for b := range leaves {
+			if b >= sub.r.Start && b <= sub.r.End {
+				delete(leaves, b)
+			}
+		}
+		sub.e.Estimate = (sub.e.Lower + sub.e.Upper) / 2
+		res.Add(sub.e)
+	}
+	exact := 0
+	for _, ok := range leaves {
+		if ok {
+			exact++
+		}
+	}
+	res.Add(&BlockCountEstimate{Estimate: len(leaves), Lower: exact, Upper: len(leaves)})
+	return res, tr.stats
+}
+
+func (cscForest *CSCForest) EstimateBlockCount(item string, start_block int, end_block int) (*BlockCountEstimate, *QueryStats) {
+	return cscForest.EstimateBlockCountWithLevel(item, start_block, end_block, DefaultEstimateLevel)
+}
+
+// 依次估计每棵与查询范围有重合的 CSCTree，结果累加
+func (cscForest *CSCForest) EstimateBlockCountWithLevel(item string, start_block int, end_block int, level int) (*BlockCountEstimate, *QueryStats) {
+	start_time := time.Now()
+	res := &BlockCountEstimate{}
+	stats := NewQueryStats()
+	defer func() {
+		stats.TotalTime = time.Since(start_time)
+		cscForest.Stats.Record("EstimateBlockCount", stats)
+	}()
+	block_range := block.NewBlockRange(start_block, end_block)
+	for _, t := range cscForest.CSCForest {
+		if t.IsEmpty() || !t.Root.GetRange().Intersect(block_range) {
+			continue
+		}
+		e, treeStats := t.EstimateBlockCountWithLevel(item, start_block, end_block, level)
+		res.Add(e)
+		stats.Add(treeStats)
+	}
+	return res, stats
+}
@@ -0,0 +1,76 @@
+package csctree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateBlockCount(t *testing.T) {
+	forest, truth := buildTestForest(testContext(5, false), 96, 4)
+	ranges := [][2]int{{0, 95}, {16, 47}, {5, 70}}
+	for account, blocks := range truth {
+		for _, r := range ranges {
+			// 不跳过任何子树时与 GetWithRange 去重后的结果数量一致
+			nodes, _ := forest.GetWithRange(account, r[0], r[1])
+			exact := make(map[int]bool)
+			for _, n := range nodes {
+				exact[n.GetRange().Start] = true
+			}
+			e, _ := forest.EstimateBlockCountWithLevel(account, r[0], r[1], 6)
+			assert.Equal(t, len(exact), e.Estimate, account)
+			// 没有跳过任何子树时上界就是找到的叶子节点数量
+			assert.Equal(t, e.Estimate, e.Upper, account)
+
+			count := 0
+			for _, b := range blocks {
+				if b >= r[0] && b <= r[1] {
+					count++
+				}
+			}
+			e, _ = forest.EstimateBlockCount(account, r[0], r[1])
+			assert.LessOrEqual(t, e.Lower, e.Estimate, account)
+			assert.LessOrEqual(t, e.Estimate, e.Upper, account)
+			assert.LessOrEqual(t, count, e.Upper, account)
+		}
+	}
+	e, _ := forest.EstimateBlockCount("0xmissing", 0, 95)
+	assert.Equal(t, 0, e.Upper)
+}
+
+// BloomFilter 误判较多时会回溯并推入嵌套的子树，嵌套的子树只计数一次，上下界仍然包含真实值。
+// 回溯只会检查上一层，连续两层假阳时遍历本身可能漏报，因此上界只与遍历能找到的真实区块比较
+func TestEstimateBlockCountNested(t *testing.T) {
+	ctx := testContext(6, false)
+	ctx.Config.CSCTreeConfig.BfFalsePositiveRate = 0.2
+	ctx.Config.CSCTreeConfig.SketchLevel = ctx.Config.CSCTreeConfig.MaxLevel
+	forest, truth := buildTestForest(ctx, 128, 2)
+	truth["0xmissing"] = nil
+	for account, blocks := range truth {
+		// 与树的边界不对齐的范围才会出现部分重合的节点
+		for start := 0; start < 128; start += 5 {
+			for end := start; end < 128; end += 9 {
+				nodes, _ := forest.GetWithRange(account, start, end)
+				found := make(map[int]bool)
+				for _, n := range nodes {
+					found[n.GetRange().Start] = true
+				}
+				count, reachable := 0, 0
+				for _, b := range blocks {
+					if b >= start && b <= end {
+						count++
+						if found[b] {
+							reachable++
+						}
+					}
+				}
+				for _, level := range []int{2, DefaultEstimateLevel} {
+					e, _ := forest.EstimateBlockCountWithLevel(account, start, end, level)
+					assert.LessOrEqual(t, e.Lower, count, account)
+					assert.LessOrEqual(t, reachable, e.Upper, account)
+					assert.LessOrEqual(t, e.Upper, end-start+1, account)
+				}
+			}
+		}
+	}
+}
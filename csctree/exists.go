@@ -0,0 +1,69 @@
+package csctree
+
+import (
+	"time"
+
+	"github.com/liuys-dase/csc-tree/block"
+)
+
+// 判断 item 是否在 [start_block, end_block] 中出现过，找到第一个符合条件的叶子节点后立即停止，不构造结果列表
+func (t *CSCTree) Exists(item string, start_block int, end_block int) (bool, *QueryStats) {
+	start_time := time.Now()
+	if t.IsEmpty() {
+		return false, NewQueryStats()
+	}
+	block_range := block.NewBlockRange(start_block, end_block)
+	tr := t.newTraversal(item, block_range)
+	defer func() { tr.stats.TotalTime = time.Since(start_time) }()
+	// 使用栈（DFS）而不是队列，尽快到达叶子节点
+	stack := NewDeque()
+	found := false
+	tr.push = func(qp QueryPlan) { stack.PushBack(qp) }
+	tr.emit = func(n Node) { found = true }
+	stack.PushBack(NewQueryPlan(t.Root, nil, false, false))
+	for stack.Size() > 0 && !found {
+		qp := stack.RemoveFromBack().(QueryPlan)
+		outcome := tr.expand(qp)
+		// 由 BloomFilter 推入的节点在 CSCR 中查到了结果，说明父节点的 BloomFilter 命中是真的：
+		// item 同时出现在父节点及其兄弟节点中，只要其中之一完全落在查询范围内即可直接返回
+		if outcome == outcomeCSCRHit && qp.IsPushedByBf && covers(block_range, qp.ParentNode) {
+			return true, tr.stats
+		}
+	}
+	return found, tr.stats
+}
+
+// 节点或其兄弟节点的范围完全落在 r 中
+func covers(r *block.BlockRange, n Node) bool {
+	if within(r, n.GetRange()) {
+		return true
+	}
+	sibling := n.GetSiblingNode()
+	return sibling != nil && within(r, sibling.GetRange())
+}
+
+func within(outer *block.BlockRange, inner *block.BlockRange) bool {
+	return outer.Start <= inner.Start && inner.End <= outer.End
+}
+
+// 依次检查与查询范围有重合的 CSCTree，任意一棵中存在即返回
+func (cscForest *CSCForest) Exists(item string, start_block int, end_block int) (bool, *QueryStats) {
+	start_time := time.Now()
+	stats := NewQueryStats()
+	defer func() {
+		stats.TotalTime = time.Since(start_time)
+		cscForest.Stats.Record("Exists", stats)
+	}()
+	block_range := block.NewBlockRange(start_block, end_block)
+	for _, t := range cscForest.CSCForest {
+		if t.IsEmpty() || !t.Root.GetRange().Intersect(block_range) {
+			continue
+		}
+		found, treeStats := t.Exists(item, start_block, end_block)
+		stats.Add(treeStats)
+		if found {
+			return true, stats
+		}
+	}
+	return false, stats
+}
@@ -0,0 +1,37 @@
+package csctree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExists(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		forest, truth := buildTestForest(testContext(5, useFlatten), 96, 3)
+		ranges := [][2]int{{0, 95}, {0, 0}, {10, 20}, {17, 17}, {40, 63}, {50, 90}, {95, 95}}
+		for account := range truth {
+			for _, r := range ranges {
+				// 结果与 Get 在查询范围内是否有结果保持一致
+				expected := false
+				for _, tree := range forest.CSCForest {
+					nodes, _ := tree.Get(account)
+					if useFlatten {
+						nodes, _ = tree.GetWithKLeafs(account)
+					}
+					for _, n := range nodes {
+						if n.GetRange().Start >= r[0] && n.GetRange().End <= r[1] {
+							expected = true
+						}
+					}
+				}
+				exists, _ := forest.Exists(account, r[0], r[1])
+				assert.Equal(t, expected, exists, "%v %v", account, r)
+			}
+		}
+		exists, _ := forest.Exists("0xmissing", 0, 95)
+		assert.False(t, exists)
+		exists, _ = forest.Exists("0x0000", 1000, 2000)
+		assert.False(t, exists)
+	}
+}
@@ -0,0 +1,163 @@
+package csctree
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/liuys-dase/csc-tree/block"
+)
+
+// 查询过程中访问的一个 QueryPlan，Children 为展开该 QueryPlan 时推入队列的 QueryPlan
+type TraceNode struct {
+	Nid            int          `json:"nid"`
+	Type           string       `json:"type"`
+	Range          string       `json:"range"`
+	Level          int          `json:"level"`
+	PushedBy       string       `json:"pushed_by"` // root、bf、cscr 或 backtrack
+	IgnoreBfCheck  bool         `json:"ignore_bf_check,omitempty"`
+	BfHit          *bool        `json:"bf_hit,omitempty"`
+	CSCRCandidates []int        `json:"cscr_candidates,omitempty"`
+	FoundNodes     []string     `json:"found_nodes,omitempty"`  // findNodeById 的结果，未找到时为 "<nid>:missing"
+	FlattenCSCR    []string     `json:"flatten_cscr,omitempty"` // 查询 FlattenCSCR 得到的叶子节点
+	Results        []string     `json:"results,omitempty"`      // 展开该 QueryPlan 时确认的叶子节点
+	Outcome        string       `json:"outcome"`
+	Children       []*TraceNode `json:"children,omitempty"`
+}
+
+func (o planOutcome) String() string {
+	switch o {
+	case outcomeSkipped:
+		return "skipped"
+	case outcomeRoot:
+		return "root"
+	case outcomeBfHit:
+		return "bf_hit"
+	case outcomeCSCRHit:
+		return "cscr_hit"
+	case outcomeMiss:
+		return "miss"
+	case outcomeBacktrack:
+		return "backtrack"
+	default:
+		return "unknown"
+	}
+}
+
+func newTraceNode(qp QueryPlan) *TraceNode {
+	pushedBy := "cscr"
+	switch {
+	case qp.IgnoreBfCheck:
+		pushedBy = "backtrack"
+	case qp.IsPushedByBf:
+		pushedBy = "bf"
+	case qp.ParentNode == nil:
+		pushedBy = "root"
+	}
+	n := qp.N
+	return &TraceNode{
+		Nid:           n.GetNid(),
+		Type:          n.GetNodeType().String(),
+		Range:         n.GetRange().String(),
+		Level:         n.GetLevel(),
+		PushedBy:      pushedBy,
+		IgnoreBfCheck: qp.IgnoreBfCheck,
+	}
+}
+
+func (tn *TraceNode) JSON() string {
+	data, _ := json.MarshalIndent(tn, "", "  ")
+	return string(data)
+}
+
+// 与 Get（或 GetWithKLeafs）相同的查询，同时记录访问的每个 QueryPlan
+func (t *CSCTree) Explain(item string) ([]Node, *TraceNode, *QueryStats) {
+	return t.explain(item, nil)
+}
+
+// 与 GetWithRange 相同的查询，同时记录访问的每个 QueryPlan
+func (t *CSCTree) ExplainWithRange(item string, start_block int, end_block int) ([]Node, *TraceNode, *QueryStats) {
+	return t.explain(item, block.NewBlockRange(start_block, end_block))
+}
+
+func (t *CSCTree) explain(item string, rng *block.BlockRange) ([]Node, *TraceNode, *QueryStats) {
+	start_time := time.Now()
+	res := make([]Node, 0)
+	if t.IsEmpty() {
+		return res, nil, NewQueryStats()
+	}
+	tr := t.newTraversal(item, rng)
+	defer func() { tr.stats.TotalTime = time.Since(start_time) }()
+	// 队列中保存 QueryPlan 及其对应的记录
+	type tracedPlan struct {
+		qp  QueryPlan
+		rec *TraceNode
+	}
+	queue := NewDeque()
+	tr.push = func(qp QueryPlan) {
+		rec := newTraceNode(qp)
+		tr.trace.Children = append(tr.trace.Children, rec)
+		queue.PushBack(tracedPlan{qp, rec})
+	}
+	tr.emit = func(n Node) {
+		tr.trace.Results = append(tr.trace.Results, n.GetRange().String())
+		res = append(res, n)
+	}
+	root := newTraceNode(NewQueryPlan(t.Root, nil, false, false))
+	queue.PushBack(tracedPlan{NewQueryPlan(t.Root, nil, false, false), root})
+	for queue.Size() > 0 {
+		p := queue.RemoveFromFront().(tracedPlan)
+		tr.trace = p.rec
+		p.rec.Outcome = tr.expand(p.qp).String()
+	}
+	return res, root, tr.stats
+}
+
+// 森林中每棵 CSCTree 的查询记录
+type ForestTrace struct {
+	Item  string       `json:"item"`
+	Trees []*TraceNode `json:"trees"`
+	Stats *QueryStats  `json:"stats"`
+}
+
+func (ft *ForestTrace) JSON() string {
+	data, _ := json.MarshalIndent(ft, "", "  ")
+	return string(data)
+}
+
+func (cscForest *CSCForest) Explain(item string) ([]Node, *ForestTrace) {
+	return cscForest.explainAll("Explain", item, func(t *CSCTree) ([]Node, *TraceNode, *QueryStats) {
+		return t.Explain(item)
+	})
+}
+
+func (cscForest *CSCForest) ExplainWithRange(item string, start_block int, end_block int) ([]Node, *ForestTrace) {
+	return cscForest.explainAll("ExplainWithRange", item, func(t *CSCTree) ([]Node, *TraceNode, *QueryStats) {
+		return t.ExplainWithRange(item, start_block, end_block)
+	})
+}
+
+func (cscForest *CSCForest) explainAll(op string, item string, explain func(t *CSCTree) ([]Node, *TraceNode, *QueryStats)) ([]Node, *ForestTrace) {
+	start_time := time.Now()
+	nodes := make([]Node, 0)
+	ft := &ForestTrace{Item: item, Trees: make([]*TraceNode, 0), Stats: NewQueryStats()}
+	for _, t := range cscForest.CSCForest {
+		if t.IsEmpty() {
+			continue
+		}
+		res, trace, stats := explain(t)
+		nodes = append(nodes, res...)
+		ft.Trees = append(ft.Trees, trace)
+		ft.Stats.Add(stats)
+	}
+	ft.Stats.TotalTime = time.Since(start_time)
+	cscForest.Stats.Record(op, ft.Stats)
+	return nodes, ft
+}
+
+func describeNode(nid int, n Node) string {
+	if n == nil {
+		return fmt.Sprintf("%d:missing", nid)
+	}
+	return fmt.Sprintf("%d:%v%v", nid, n.GetNodeType(), n.GetRange())
+}
@@ -0,0 +1,46 @@
+package csctree
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func countTrace(tn *TraceNode) int {
+	count := 1
+	for _, c := range tn.Children {
+		count += countTrace(c)
+	}
+	return count
+}
+
+func TestExplain(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		forest, truth := buildTestForest(testContext(5, useFlatten), 64, 7)
+		for account := range truth {
+			expected, _ := forest.Get(account)
+			nodes, trace := forest.Explain(account)
+			assert.Equal(t, blockNumbers(expected), blockNumbers(nodes), account)
+			assert.Equal(t, account, trace.Item)
+			for _, tn := range trace.Trees {
+				assert.Equal(t, "ROOT", tn.Type)
+				assert.Equal(t, "root", tn.Outcome)
+				assert.Greater(t, countTrace(tn), 1)
+			}
+			var decoded ForestTrace
+			assert.Nil(t, json.Unmarshal([]byte(trace.JSON()), &decoded))
+			assert.Equal(t, len(trace.Trees), len(decoded.Trees))
+		}
+		assert.Equal(t, len(truth), forest.Stats.Latencies["Explain"].Count)
+	}
+
+	forest, _ := buildTestForest(testContext(5, false), 64, 7)
+	_, trace := forest.ExplainWithRange("0x0000", 0, 15)
+	// 与查询范围没有重合的节点不做任何检查
+	for _, tn := range trace.Trees[1:] {
+		assert.Equal(t, "skipped", tn.Outcome)
+		assert.Empty(t, tn.Children)
+	}
+	assert.Equal(t, 1, forest.Stats.Latencies["ExplainWithRange"].Count)
+}
@@ -0,0 +1,64 @@
+package csctree
+
+import "time"
+
+// 查找 item 第一次出现的叶子节点，按区块顺序遍历，找到第一个确认的叶子节点后立即停止
+func (t *CSCTree) FirstSeen(item string) (Node, *QueryStats) {
+	return t.firstInOrder(item, false)
+}
+
+// 查找 item 最后一次出现的叶子节点，按区块逆序遍历，找到第一个确认的叶子节点后立即停止
+func (t *CSCTree) LastSeen(item string) (Node, *QueryStats) {
+	return t.firstInOrder(item, true)
+}
+
+func (t *CSCTree) firstInOrder(item string, descending bool) (Node, *QueryStats) {
+	start_time := time.Now()
+	if t.IsEmpty() {
+		return nil, NewQueryStats()
+	}
+	w := t.newOrderedWalker(item, nil, descending)
+	n := w.Next()
+	w.tr.stats.LeavesReturned = 0
+	if n != nil {
+		w.tr.stats.LeavesReturned = 1
+	}
+	w.tr.stats.TotalTime = time.Since(start_time)
+	return n, w.tr.stats
+}
+
+// 按区块顺序依次检查每棵 CSCTree，找到结果后不再检查后续的 CSCTree
+func (cscForest *CSCForest) FirstSeen(item string) (Node, *QueryStats) {
+	start_time := time.Now()
+	stats := NewQueryStats()
+	defer func() {
+		stats.TotalTime = time.Since(start_time)
+		cscForest.Stats.Record("FirstSeen", stats)
+	}()
+	for _, t := range cscForest.CSCForest {
+		n, treeStats := t.FirstSeen(item)
+		stats.Add(treeStats)
+		if n != nil {
+			return n, stats
+		}
+	}
+	return nil, stats
+}
+
+// 按区块逆序依次检查每棵 CSCTree，找到结果后不再检查更早的 CSCTree
+func (cscForest *CSCForest) LastSeen(item string) (Node, *QueryStats) {
+	start_time := time.Now()
+	stats := NewQueryStats()
+	defer func() {
+		stats.TotalTime = time.Since(start_time)
+		cscForest.Stats.Record("LastSeen", stats)
+	}()
+	for i := len(cscForest.CSCForest) - 1; i >= 0; i-- {
+		n, treeStats := cscForest.CSCForest[i].LastSeen(item)
+		stats.Add(treeStats)
+		if n != nil {
+			return n, stats
+		}
+	}
+	return nil, stats
+}
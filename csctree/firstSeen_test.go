@@ -0,0 +1,165 @@
+package csctree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/context"
+	"github.com/stretchr/testify/assert"
+)
+
+func testContext(maxLevel int, useFlatten bool) *context.Context {
+	return &context.Context{
+		Config: &config.ServerConfig{
+			CSCTreeConfig: &config.CSCTreeConfig{
+				MaxLevel:            maxLevel,
+				BfFalsePositiveRate: 0.01,
+				BfHashFuncNum:       7,
+				FingerprintSize:     8,
+				FingerprintNum:      4,
+				MaxKickAttempts:     30,
+				PartitionNum:        16,
+				RepetitionNum:       3,
+				MaxElementNumPerPar: 5,
+				SketchLevel:         0,
+				UseNodeIndex:        true,
+				LeafNum:             4,
+				UseFlatten:          useFlatten,
+			},
+		},
+	}
+}
+
+// 构造 blockNum 个区块，返回森林以及每个账户真实出现的区块（有序）
+func buildTestForest(ctx *context.Context, blockNum int, seed int64) (*CSCForest, map[string][]int) {
+	forest := NewCSCForest(ctx)
+	return forest, fillTestForest(forest, blockNum, seed)
+}
+
+// 向已经创建的森林中写入 blockNum 个区块
+func fillTestForest(forest *CSCForest, blockNum int, seed int64) map[string][]int {
+	r := rand.New(rand.NewSource(seed))
+	truth := make(map[string][]int)
+	for b := 0; b < blockNum; b++ {
+		txns := make([]string, 0)
+		seen := make(map[string]bool)
+		for i := 0; i < 12; i++ {
+			// 账户编号越小越活跃
+			sender := fmt.Sprintf("0x%04d", int(r.ExpFloat64()*8))
+			txns = append(txns, fmt.Sprintf("tx%d_%d,%d,%s,0xr%d", b, i, b, sender, r.Intn(100)))
+			if !seen[sender] {
+				seen[sender] = true
+				truth[sender] = append(truth[sender], b)
+			}
+		}
+		forest.AddwithBlock(b, txns)
+	}
+	return truth
+}
+
+func blockNumbers(nodes []Node) []int {
+	res := make([]int, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, n.GetRange().Start)
+	}
+	sort.Ints(res)
+	return res
+}
+
+func TestFirstSeenAndLastSeen(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		forest, truth := buildTestForest(testContext(5, useFlatten), 96, 1)
+		for account := range truth {
+			nodes, _ := forest.Get(account)
+			blocks := blockNumbers(nodes)
+			first, _ := forest.FirstSeen(account)
+			last, _ := forest.LastSeen(account)
+			if len(blocks) == 0 {
+				assert.Nil(t, first)
+				assert.Nil(t, last)
+				continue
+			}
+			// 与 Get 的遍历完全一致，只是提前停止
+			assert.Equal(t, blocks[0], first.GetRange().Start, account)
+			assert.Equal(t, blocks[len(blocks)-1], last.GetRange().Start, account)
+		}
+		missing, _ := forest.FirstSeen("0xmissing")
+		assert.Nil(t, missing)
+	}
+}
+
+func TestOrderedWalker(t *testing.T) {
+	forest, truth := buildTestForest(testContext(5, false), 64, 2)
+	for account := range truth {
+		for _, tree := range forest.CSCForest {
+			nodes, _ := tree.Get(account)
+			blocks := blockNumbers(nodes)
+			// 去重
+			expected := make([]int, 0)
+			for i, b := range blocks {
+				if i == 0 || b != blocks[i-1] {
+					expected = append(expected, b)
+				}
+			}
+			asc := make([]int, 0)
+			w := tree.newOrderedWalker(account, nil, false)
+			for n := w.Next(); n != nil; n = w.Next() {
+				asc = append(asc, n.GetRange().Start)
+			}
+			assert.Equal(t, expected, asc)
+		}
+	}
+}
+
+// BloomFilter 误判率很高时回溯频繁，按区块顺序遍历的结果仍然与 Get 一致
+func TestOrderedWalkerHighFPR(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		for _, fingerprintSize := range []int{3, 8} {
+			for seed := int64(1); seed <= 3; seed++ {
+				ctx := testContext(6, useFlatten)
+				ctx.Config.CSCTreeConfig.BfFalsePositiveRate = 0.4
+				ctx.Config.CSCTreeConfig.FingerprintSize = fingerprintSize
+				ctx.Config.CSCTreeConfig.Seed = seed
+				forest, truth := buildTestForest(ctx, 160, seed)
+				for account := range truth {
+					for _, tree := range forest.CSCForest {
+						nodes, _ := tree.Get(account)
+						blocks := blockNumbers(nodes)
+						expected := make([]int, 0)
+						for i, b := range blocks {
+							if i == 0 || b != blocks[i-1] {
+								expected = append(expected, b)
+							}
+						}
+						asc := make([]int, 0)
+						w := tree.newOrderedWalker(account, nil, false)
+						for n := w.Next(); n != nil; n = w.Next() {
+							asc = append(asc, n.GetRange().Start)
+						}
+						assert.Equal(t, expected, asc, account)
+						desc := make([]int, 0)
+						w = tree.newOrderedWalker(account, nil, true)
+						for n := w.Next(); n != nil; n = w.Next() {
+							desc = append([]int{n.GetRange().Start}, desc...)
+						}
+						assert.Equal(t, expected, desc, account)
+					}
+					nodes, _ := forest.Get(account)
+					blocks := blockNumbers(nodes)
+					first, _ := forest.FirstSeen(account)
+					last, _ := forest.LastSeen(account)
+					if len(blocks) == 0 {
+						assert.Nil(t, first)
+						assert.Nil(t, last)
+						continue
+					}
+					assert.Equal(t, blocks[0], first.GetRange().Start, account)
+					assert.Equal(t, blocks[len(blocks)-1], last.GetRange().Start, account)
+				}
+			}
+		}
+	}
+}
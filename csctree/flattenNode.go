@@ -2,6 +2,7 @@ package csctree
 
 import (
 	"math"
+	"sort"
 	"strconv"
 
 	"github.com/liuys-dase/csc-tree/block"
@@ -12,7 +13,7 @@ import (
 type FlattenNode struct {
 	NodeType      NodeType    // 节点类型
 	Children      []*LeafNode // 子节点
-	BloomFilter   *basicfilter.BloomFilter
+	Filter        basicfilter.MembershipFilter
 	CSCR          *cscsketch.CSCR
 	Level         int
 	NodeRange     *block.BlockRange
@@ -47,7 +48,7 @@ func NewFlattenNode(children []*LeafNode) *FlattenNode {
 	return &FlattenNode{
 		NodeType:      FLATTEN,
 		Children:      children,
-		BloomFilter:   basicfilter.NewEmptyBloomFilter(),
+		Filter:        basicfilter.NewEmptyBloomFilter(),
 		CSCR:          cscsketch.NewEmptyCSCR(),
 		Level:         int(math.Log2(float64(len(children)))) + 1,
 		NodeRange:     block.NewBlockRange(start, end),
@@ -82,8 +83,8 @@ func (i *FlattenNode) GetSenderSet() *block.AccountSet {
 	return i.SenderSet
 }
 
-func (i *FlattenNode) SetBloomFilter(bf *basicfilter.BloomFilter) {
-	i.BloomFilter = bf
+func (i *FlattenNode) SetFilter(f basicfilter.MembershipFilter) {
+	i.Filter = f
 }
 
 func (i *FlattenNode) SetSenderSet(as *block.AccountSet) {
@@ -165,6 +166,16 @@ func (l *UniqueNidList) Insert(nid int) {
 	l.NidList[nid] = true
 }
 
+// 按从小到大的顺序返回所有 nid
+func (l *UniqueNidList) Sorted() []int {
+	res := make([]int, 0, len(l.NidList))
+	for nid := range l.NidList {
+		res = append(res, nid)
+	}
+	sort.Ints(res)
+	return res
+}
+
 func (l *UniqueNidList) Union(l2 *UniqueNidList) *UniqueNidList {
 	res := NewUniqueNidList()
 	for nid := range l.NidList {
@@ -196,6 +207,16 @@ func (m *AccountMap) ToString() string {
 	return ret
 }
 
+// 按字典序返回所有地址
+func (m *AccountMap) SortedAddrs() []string {
+	res := make([]string, 0, len(m.Map))
+	for addr := range m.Map {
+		res = append(res, addr)
+	}
+	sort.Strings(res)
+	return res
+}
+
 func (m *AccountMap) AddSenderSet(senderSet *block.AccountSet) {
 	for addr, nid := range senderSet.Accounts {
 		// 如果 addr 尚未添加，则创建一个新的 UniqueNidList
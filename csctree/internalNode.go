@@ -12,7 +12,7 @@ type InternalNode struct {
 	NodeType      NodeType // 节点类型
 	LeftChild     Node     // 左孩子
 	RightChild    Node     // 右孩子
-	BloomFilter   *basicfilter.BloomFilter
+	Filter        basicfilter.MembershipFilter
 	CSCR          *cscsketch.CSCR
 	Level         int
 	NodeRange     *block.BlockRange
@@ -28,14 +28,14 @@ func NewInternalNode(leftChild Node, rightChild Node) *InternalNode {
 	rightChild.SetLeftChildFlag(false)
 	leftChild.SetSiblingNode(rightChild)
 	return &InternalNode{
-		NodeType:    INTERNAL,
-		LeftChild:   leftChild,
-		RightChild:  rightChild,
-		BloomFilter: basicfilter.NewEmptyBloomFilter(),
-		CSCR:        cscsketch.NewEmptyCSCR(),
-		Level:       leftChild.GetLevel() + 1,
-		NodeRange:   leftChild.GetRange().Merge(rightChild.GetRange()),
-		SenderSet:   nil,
+		NodeType:   INTERNAL,
+		LeftChild:  leftChild,
+		RightChild: rightChild,
+		Filter:     basicfilter.NewEmptyBloomFilter(),
+		CSCR:       cscsketch.NewEmptyCSCR(),
+		Level:      leftChild.GetLevel() + 1,
+		NodeRange:  leftChild.GetRange().Merge(rightChild.GetRange()),
+		SenderSet:  nil,
 	}
 }
 
@@ -63,8 +63,8 @@ func (i *InternalNode) GetSenderSet() *block.AccountSet {
 	return i.SenderSet
 }
 
-func (i *InternalNode) SetBloomFilter(bf *basicfilter.BloomFilter) {
-	i.BloomFilter = bf
+func (i *InternalNode) SetFilter(f basicfilter.MembershipFilter) {
+	i.Filter = f
 }
 
 func (i *InternalNode) SetSenderSet(as *block.AccountSet) {
@@ -1,6 +1,7 @@
 package csctree
 
 import (
+	stdcontext "context"
 	"strconv"
 	"time"
 
@@ -139,11 +140,11 @@ func (t *CSCTree) CreateInternalNodeUponFlatten(leftNode *FlattenNode, rightNode
 	intersection := leftNode.AccountMap.Intersect(rightNode.AccountMap)
 	// log.Printf("intersection of %v and %v: %v\n", leftNode.GetNid(), rightNode.GetNid(), intersection)
 
-	// step2. 将交集加入 leftNode 和 rightNode 的 bf
-	bf := t.NewBloomFilter(len(intersection), ctx)
+	// step2. 将交集加入 leftNode 和 rightNode 的过滤器
+	bf := t.NewFilter(len(intersection), leftNode.GetLevel(), ctx)
 	bf.BatchAdd(intersection)
-	leftNode.SetBloomFilter(bf)
-	rightNode.SetBloomFilter(bf)
+	leftNode.SetFilter(bf)
+	rightNode.SetFilter(bf)
 
 	// step3. 生成 FlattenCSCR
 	cscr_left := t.InitializeFlattenCSCR(leftNode, intersection, ctx)
@@ -205,21 +206,35 @@ func (t *CSCTree) InitializeFlattenCSCR(node *FlattenNode, intersection []string
 		return flattenCSCR
 	}
 
-	retry_flag := true
-	for retry_flag {
+	// 按地址和 nid 的顺序插入，保证相同的随机数来源得到相同的 FlattenCSCR；空间不足时对应的 CSC 原地扩容
+	flattenCSCR.EnableGrowth()
+	full := false
+	for _, k := range am.SortedAddrs() {
+		for _, nid := range am.Map[k].Sorted() {
+			if !flattenCSCR.Add(k, strconv.Itoa(nid)) {
+				full = true
+			}
+		}
+	}
+	flattenCSCR.Seal()
+	// 扩容达到上限仍然放不下时改用 HashMap，避免漏报
+	if full {
+		flattenCSCR = cscsketch.NewCSCRWithHashMap()
 		for k, nidList := range am.Map {
 			for nid := range nidList.NidList {
-				if !flattenCSCR.Add(k, strconv.Itoa(nid)) {
-					flattenCSCR.Double()
-					retry_flag = true
-					break
-				}
-				retry_flag = false
+				flattenCSCR.Add(k, strconv.Itoa(nid))
 			}
-			if retry_flag {
-				break
+		}
+	}
+	if ctx.Config.CSCTreeConfig.DetectAnomalies {
+		items, nids := make([]string, 0), make([]int, 0)
+		for k, nidList := range am.Map {
+			for nid := range nidList.NidList {
+				items = append(items, k)
+				nids = append(nids, nid)
 			}
 		}
+		t.detectAnomalies(ctx, node.GetLevel(), node.GetRange(), COMPONENT_FLATTEN_CSCR, flattenCSCR, items, nids)
 	}
 	// if node.GetRange().Start == 18000124 && node.GetRange().End == 18000127 {
 	// 	target := "0xd108fd0e8c8e71552a167e7a44ff1d345d233ba6"
@@ -234,187 +249,21 @@ func (t *CSCTree) InitializeFlattenCSCR(node *FlattenNode, intersection []string
 }
 
 // 带有 FlattenNode 的查询
-func (t *CSCTree) GetWithKLeafs(item string) []Node {
-	t.CscCacheList.Clear()
-	res := make([]Node, 0)
-	if t.IsEmpty() {
-		return res
-	}
-	queue := NewDeque()
-	queue.PushBack(NewQueryPlan(t.Root, nil, false, false))
-	for queue.Size() > 0 {
-		qp := queue.RemoveFromFront().(QueryPlan)
-		node := qp.N
-		switch n := node.(type) {
-		// 如果是 RootNode，则将左孩子加入队列（只需要加入一个节点，另一个可以通过 sibling 指针获取）
-		case *RootNode:
-			queue.PushBack(NewQueryPlan(n.LeftChild, nil, false, false))
-		case *InternalNode:
-			// log.Printf("check internal node: %v\n", n.GetRange())
-			start_time := time.Now()
-			hit := n.BloomFilter.GetWithHashGroup(item, t.HashGroup)
-			t.TimeCounter.AddBFTime(start_time)
-			if hit && !qp.IgnoreBfCheck {
-				// log.Printf("bloom filter (internal node) between %v and %v is true\n", n.GetRange(), n.GetSiblingNode().GetRange())
-				// log.Printf("	put %v and %v into queue\n", n.GetLeftChild().GetRange(), n.GetSiblingNode().GetLeftChild().GetRange())
-				// 先检查是否在 BloomFilter 中，如果在，则将左右孩子加入队列
-				// 将左孩子和兄弟节点的左孩子加入队列
-				queue.PushBack(NewQueryPlan(n.LeftChild, n, true, false))
-				queue.PushBack(NewQueryPlan(n.GetSiblingNode().GetLeftChild(), n, true, false))
-			} else {
-				// log.Printf("bloom filter (internal node) between %v and %v is false\n", n.GetRange(), n.GetSiblingNode().GetRange())
-				// 如果不在 BloomFilter 中，则需要进一步检查 CSCR
-				start_time := time.Now()
-				cscr_res := n.CSCR.GetWithCache(item, t.CscCacheList)
-				// cscr_res := n.CSCR.Get(item)
-				t.TimeCounter.AddCSCRTime(start_time)
-				// log.Printf("	result of cscr: %v\n", cscr_res)
-				if len(cscr_res) == 0 && qp.IsPushedByBf {
-					// 如果是由 BloomFilter 推入的节点，且 CSCR 为空，说明布隆过滤器假阳了，需要回溯检查上一层的 csc
-					queue.PushBack(NewQueryPlan(qp.ParentNode, nil, false, true))
-					continue
-				}
-				for _, nodeId := range cscr_res {
-					// 向下遍历树，找到符合条件的节点
-					// log.Printf("	search node: %v\n", nodeId)
-					nid, _ := strconv.Atoi(nodeId)
-					foundNode := t.findNodeById(n, nid)
-					if foundNode != nil {
-						// log.Printf("	found node: %v\n", foundNode.GetRange())
-						// 叶子节点直接返回
-						if foundNode.GetNodeType() == LEAF {
-							// log.Printf("    foundNode is leaf node\n")
-							res = append(res, foundNode)
-						} else if foundNode.GetNodeType() == FLATTEN {
-							// log.Printf("    foundNode is flatten node\n")
-							// 如果找到的节点是 FlattenNode，则直接将其 flattenCSCR 中的节点加入 res
-							res = append(res, t.searchFlattenCSCR(foundNode.(*FlattenNode), item)...)
-							// queue.PushBack(NewQueryPlan(foundNode, foundNode, false, false))
-						} else {
-							// log.Printf("    foundNode is internal node\n")
-							queue.PushBack(NewQueryPlan(foundNode.GetLeftChild(), foundNode, false, false))
-							// if foundNode.GetLeftChild().GetNodeType() == LEAF {
-							// 	log.Printf("    child of foundNode is leaf node\n")
-							// 	queue.PushBack(NewQueryPlan(foundNode.GetLeftChild(), foundNode, false, false))
-							// } else if foundNode.GetLeftChild().GetNodeType() == FLATTEN {
-							// 	// todo
-							// 	queue.PushBack(NewQueryPlan(foundNode.GetLeftChild(), foundNode, false, false))
-							// } else {
-							// 	log.Printf("    child of foundNode is not leaf node\n")
-							// 	queue.PushBack(NewQueryPlan(foundNode.GetLeftChild().GetLeftChild(), foundNode, false, false))
-							// 	queue.PushBack(NewQueryPlan(foundNode.GetRightChild().GetLeftChild(), foundNode, false, false))
-							// }
-						}
-					}
-				}
-			}
-		case *LeafNode:
-			// 先检查是否在 BloomFilter 中，如果在，则将其与兄弟节点加入 res
-			start_time := time.Now()
-			hit := n.BloomFilter.GetWithHashGroup(item, t.HashGroup)
-			t.TimeCounter.AddBFTime(start_time)
-			if hit {
-				// log.Printf("bloom filter (leaf node) between %v and %v is true\n", n.GetRange(), n.GetSiblingNode().GetRange())
-				// log.Printf("	put %v and %v into res\n", n.GetRange(), n.GetSiblingNode().GetRange())
-				res = append(res, n, n.GetSiblingNode())
-			} else {
-				// 如果不在 BloomFilter 中，则需要进一步检查 CSCR
-				start_time := time.Now()
-				cscr_res := n.CSCR.GetWithCache(item, t.CscCacheList)
-				// cscr_res := n.CSCR.Get(item)
-				t.TimeCounter.AddCSCRTime(start_time)
-				// log.Printf("bloom filter (leaf node) between %v and %v is false\n", n.GetRange(), n.GetSiblingNode().GetRange())
-				// log.Printf("	result of cscr: %v\n", cscr_res)
-				if len(cscr_res) == 0 && qp.IsPushedByBf {
-					// 如果是由 BloomFilter 推入的节点，且 CSCR 为空，则说明父节点的 bf 假阳了
-					queue.PushBack(NewQueryPlan(qp.ParentNode, nil, false, true))
-					continue
-				}
-				for _, nodeId := range cscr_res {
-					// 由于已经是叶子节点，所以不需要向下遍历
-					nid, _ := strconv.Atoi(nodeId)
-					if n.GetNid() == nid {
-						res = append(res, n)
-					} else {
-						res = append(res, n.GetSiblingNode())
-					}
-				}
-			}
-		case *FlattenNode:
-			// 先检查 bloomFilter，如果在，直接检查两个 FlattenNode 的 FlattenCSCR
-			// log.Printf("check flattenNode: %v\n", n.GetRange())
-			start_time := time.Now()
-			hit := n.BloomFilter.GetWithHashGroup(item, t.HashGroup)
-			t.TimeCounter.AddBFTime(start_time)
-			if hit && !qp.IgnoreBfCheck {
-				// log.Printf("bloom filter (flatten node) between %v and %v is true\n", n.GetRange(), n.GetSiblingNode().GetRange())
-				// 分别查左右节点
-				left_res := t.searchFlattenCSCR(n, item)
-				right_res := t.searchFlattenCSCR(n.GetSiblingNode().(*FlattenNode), item)
-				// 防止假阳
-				if (len(left_res) == 0 || len(right_res) == 0) && qp.IsPushedByBf {
-					// 把自己重新加入队列，并且标记为忽略 BloomFilter 检查
-					// 因为如果两个 flattencscr 的结果都为空，说明是 flattennode 的 bloomfilter 假阳了
-					queue.PushBack(NewQueryPlan(qp.N, nil, false, true))
-					continue
-				} else {
-					res = append(res, left_res...)
-					res = append(res, right_res...)
-				}
-				// // 先查左节点
-				// res = append(res, t.searchFlattenCSCR(n, item)...)
-				// // 再查右节点
-				// res = append(res, t.searchFlattenCSCR(n.GetSiblingNode().(*FlattenNode), item)...)
-			} else {
-				// log.Printf("bloom filter (flatten node) between %v and %v is false\n", n.GetRange(), n.GetSiblingNode().GetRange())
-				start_time := time.Now()
-				cscr_res := n.CSCR.GetWithCache(item, t.CscCacheList)
-				// cscr_res := n.CSCR.Get(item)
-				t.TimeCounter.AddCSCRTime(start_time)
-				// 防止 BloomFilter 假阳，需要回溯
-				if len(cscr_res) == 0 && qp.IsPushedByBf {
-					// log.Printf("    result of cscr is empty\n")
-					queue.PushBack(NewQueryPlan(qp.ParentNode, nil, false, true))
-					continue
-				}
-				for _, nid := range cscr_res {
-					// log.Printf("    nid of cscr res: %v\n", nid)
-					// 如果 nodeId 为 FlattenNode 的 id，需要进一步查 FlattenCSCR
-					nid, _ := strconv.Atoi(nid)
-					if nid == n.GetNid() {
-						// log.Printf("    nid is equal to nid of flattenNode\n")
-						res = append(res, t.searchFlattenCSCR(n, item)...)
-					} else if nid == n.GetSiblingNode().GetNid() {
-						// log.Printf("    nid is equal to nid of sibling of flattenNode\n")
-						res = append(res, t.searchFlattenCSCR(n.GetSiblingNode().(*FlattenNode), item)...)
-					} else {
-						// log.Printf("    nid is child id\n")
-						// 如果 nodeId 为叶子节点的 id，直接返回（需要判断是左节点还是右节点）
-						leaf := n.GetChildById(nid)
-						if leaf != nil {
-							res = append(res, leaf)
-						} else {
-							leaf = n.GetSiblingNode().(*FlattenNode).GetChildById(nid)
-							if leaf != nil {
-								res = append(res, leaf)
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-	return res
+func (t *CSCTree) GetWithKLeafs(item string) ([]Node, *QueryStats) {
+	res, stats, _ := t.GetWithKLeafsWithContext(stdcontext.Background(), item)
+	return res, stats
 }
 
 // 搜索 FlattenNode 的 FlattenCSCR，返回符合条件的节点
-func (t *CSCTree) searchFlattenCSCR(node *FlattenNode, item string) []Node {
+func (t *CSCTree) searchFlattenCSCR(node *FlattenNode, item string, stats *QueryStats) []Node {
 	// log.Printf("check flattenCSCR of FlattenNode: %v\n", node.GetRange())
 	res := make([]Node, 0)
 	start_time := time.Now()
-	nidList := node.FlattenCSCR.GetWithCache(item, t.CscCacheList)
+	nidList, candidates := node.FlattenCSCR.GetWithCacheCount(item, t.CscCacheList)
 	// nidList := node.FlattenCSCR.Get(item)
-	t.TimeCounter.AddCSCRTime(start_time)
+	stats.CSCRTime += time.Since(start_time)
+	stats.CSCRProbes++
+	stats.PartitionsScanned += candidates
 	for _, nid := range nidList {
 		nodeId, _ := strconv.Atoi(nid)
 		child := node.GetChildById(nodeId)
@@ -429,7 +278,7 @@ func (t *CSCTree) searchFlattenCSCR(node *FlattenNode, item string) []Node {
 func (t *CSCTree) AddWithBlockWithKLeafs(blockNumber int, txns []string, ctx *context.Context) bool {
 	leafNode := NewLeafNode(blockNumber)
 	leafNode.SetNid(t.GlobalNid)
-	leafNode.SetSenderSet(block.NewAccountSetFromBlock(blockNumber, txns, true, leafNode.Nid))
+	leafNode.SetSenderSet(block.NewAccountSetFromBlock(blockNumber, txns, t.Role == SENDER, leafNode.Nid))
 	t.updateIndex(leafNode)
 	t.GlobalNid++
 	return t.AddWithKLeafs(leafNode, ctx)
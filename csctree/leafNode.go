@@ -44,7 +44,7 @@ type Node interface {
 	// 设置交易列表
 	SetSenderSet(as *block.AccountSet)
 	// 设置布隆过滤器
-	SetBloomFilter(bf *basicfilter.BloomFilter)
+	SetFilter(f basicfilter.MembershipFilter)
 	// 设置 CSCR
 	SetCSCR(cscr *cscsketch.CSCR)
 	// 重写 String() 方法
@@ -68,26 +68,26 @@ type Node interface {
 }
 
 type LeafNode struct {
-	NodeType      NodeType                 // 节点类型
-	BloomFilter   *basicfilter.BloomFilter // 布隆过滤器(边/热点)
-	CSCR          *cscsketch.CSCR          // CSC(冷点)
-	Level         int                      // 节点所在层级
-	NodeRange     *block.BlockRange        // 节点负责的数据范围
-	SenderSet     *block.AccountSet        // 每个叶子结点对应一个区块
-	LeftChildFlag bool                     // 判断是否是左孩子
-	SiblingNode   Node                     // 兄弟节点的指针
+	NodeType      NodeType                     // 节点类型
+	Filter        basicfilter.MembershipFilter // 兄弟节点交集的过滤器(边/热点)
+	CSCR          *cscsketch.CSCR              // CSC(冷点)
+	Level         int                          // 节点所在层级
+	NodeRange     *block.BlockRange            // 节点负责的数据范围
+	SenderSet     *block.AccountSet            // 每个叶子结点对应一个区块
+	LeftChildFlag bool                         // 判断是否是左孩子
+	SiblingNode   Node                         // 兄弟节点的指针
 	Nid           int
 }
 
 // 创建一个新的 LeafNode, 并且 senderset 为空
 func NewLeafNode(singleRange int) *LeafNode {
 	return &LeafNode{
-		NodeType:    LEAF,
-		BloomFilter: basicfilter.NewEmptyBloomFilter(),
-		CSCR:        cscsketch.NewEmptyCSCR(),
-		Level:       1,
-		NodeRange:   block.NewBlockRange(singleRange, singleRange),
-		SenderSet:   nil,
+		NodeType:  LEAF,
+		Filter:    basicfilter.NewEmptyBloomFilter(),
+		CSCR:      cscsketch.NewEmptyCSCR(),
+		Level:     1,
+		NodeRange: block.NewBlockRange(singleRange, singleRange),
+		SenderSet: block.NewAccountSet(0),
 	}
 }
 
@@ -131,8 +131,8 @@ func (l *LeafNode) SetSenderSet(as *block.AccountSet) {
 	l.SenderSet = as
 }
 
-func (l *LeafNode) SetBloomFilter(bf *basicfilter.BloomFilter) {
-	l.BloomFilter = bf
+func (l *LeafNode) SetFilter(f basicfilter.MembershipFilter) {
+	l.Filter = f
 }
 
 func (l *LeafNode) SetCSCR(cscr *cscsketch.CSCR) {
@@ -0,0 +1,77 @@
+package csctree
+
+import (
+	"github.com/liuys-dase/csc-tree/context"
+)
+
+const (
+	NodeOverheadBytes = 128 // 每个节点除 BF 与 CSC 之外的固定开销（结构体、指针、NodeIndex 等）的估计值
+	HashMapEntryBytes = 48  // HashMap CSCR 中每个 (账户, 节点) 对的估计值
+	DegradeThreshold  = 0.9 // 预计的内存占用超过 MemoryLimit 的该比例时开始降级
+	// BloomFilter 与 CSC 同时假阳时回溯可能漏报，因此降级的幅度需要有下限
+	MaxDegradedBfFpr       = 0.1 // 降级后 BloomFilter 误判率的上限
+	MinDegradedFingerprint = 6   // 降级后指纹位数的下限
+	degradeFprFactor       = 2   // 每降级一次 BloomFilter 误判率乘以该系数
+	degradeFingerprintStep = 2   // 每降级一次指纹减少的位数
+)
+
+// 估计 CSCTree 占用的内存（字节）：所有 BF 与 CSC（包括 FlattenCSCR）的大小，加上 HashMap 与节点的固定开销
+func (t *CSCTree) MemoryUsage() int {
+	if t.Root == nil {
+		return 0
+	}
+	ts := t.TreeStats()
+	bits := 0
+	hashMapEntries := 0
+	for _, total := range ts.Totals {
+		bits += total.Bits
+		hashMapEntries += total.HashMapEntries
+	}
+	nodes := 0
+	for _, node := range t.BFS() {
+		nodes++
+		if n, ok := node.(*FlattenNode); ok {
+			nodes += len(n.Children)
+		}
+	}
+	return bits/8 + hashMapEntries*HashMapEntryBytes + nodes*NodeOverheadBytes
+}
+
+// 已构建完成的 CSCTree 的内存占用加上当前 CSCTree 的内存占用
+func (cscForest *CSCForest) MemoryUsage() int {
+	return cscForest.usage + cscForest.CSCForest[cscForest.Current].MemoryUsage()
+}
+
+// 假设下一棵 CSCTree 与最近一棵构建完成的 CSCTree 大小相同，判断是否接近内存上限
+func (cscForest *CSCForest) nearMemoryLimit() bool {
+	if cscForest.MemoryLimit <= 0 {
+		return false
+	}
+	projected := cscForest.usage + cscForest.lastUsage
+	return float64(projected) > DegradeThreshold*float64(cscForest.MemoryLimit)
+}
+
+// 在原始配置的基础上降级 level 次：提高 BloomFilter 误判率、缩短指纹，flatten 模式下增大 LeafNum；
+// 已经达到下限的参数保持不变，降级后的配置校验失败时停止降级并使用上一次合法的配置，因此写入不会失败
+func degradedContext(ctx *context.Context, level int) *context.Context {
+	c := *ctx.Config.CSCTreeConfig
+	for i := 0; i < level; i++ {
+		next := c
+		next.BfFalsePositiveRate = min(next.BfFalsePositiveRate*degradeFprFactor, MaxDegradedBfFpr)
+		if next.FingerprintSize > MinDegradedFingerprint {
+			next.FingerprintSize = max(next.FingerprintSize-degradeFingerprintStep, MinDegradedFingerprint)
+		}
+		// FlattenNode 之上至少保留一层 InternalNode
+		if next.UseFlatten && 1<<(next.MaxLevel-3) >= next.LeafNum*2 {
+			next.LeafNum *= 2
+		}
+		if next.Validate() != nil {
+			break
+		}
+		c = next
+	}
+	conf := *ctx.Config
+	conf.CSCTreeConfig = &c
+	// 与原始配置共用随机数来源
+	return &context.Context{Config: &conf, Rng: ctx.Rand()}
+}
@@ -0,0 +1,61 @@
+package csctree
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		ctx := testContext(6, useFlatten)
+		unlimited, _ := buildTestForest(ctx, 160, 1)
+		assert.Empty(t, unlimited.ForestStats().DegradedTrees)
+
+		forest := NewCSCForest(ctx)
+		// 大约能容纳 3 棵使用原始配置的 CSCTree
+		forest.MemoryLimit = unlimited.CSCForest[0].MemoryUsage() * 3
+		truth := fillTestForest(forest, 160, 1)
+		assert.Equal(t, len(unlimited.CSCForest), len(forest.CSCForest))
+
+		fs := forest.ForestStats()
+		assert.NotEmpty(t, fs.DegradedTrees)
+		assert.Equal(t, 0, fs.Trees[0].DegradeLevel)
+		for i := 1; i < len(fs.Trees); i++ {
+			// 降级次数单调不减
+			assert.True(t, fs.Trees[i].DegradeLevel >= fs.Trees[i-1].DegradeLevel)
+		}
+		last := fs.Trees[len(fs.Trees)-1]
+		assert.True(t, last.Config.BfFalsePositiveRate > ctx.Config.CSCTreeConfig.BfFalsePositiveRate)
+		assert.True(t, last.Config.FingerprintSize < ctx.Config.CSCTreeConfig.FingerprintSize)
+		if useFlatten {
+			assert.True(t, last.Config.LeafNum > ctx.Config.CSCTreeConfig.LeafNum)
+		}
+		// 原始配置不受影响
+		assert.Equal(t, 0.01, ctx.Config.CSCTreeConfig.BfFalsePositiveRate)
+		assert.True(t, forest.MemoryUsage() < unlimited.MemoryUsage())
+		assert.True(t, strings.HasSuffix(strings.Split(fs.CSV(), "\n")[0], ",degrade_level"))
+
+		// 降级后仍然没有漏报
+		for account, blocks := range truth {
+			nodes, _ := forest.Get(account)
+			assert.Subset(t, blockNumbers(nodes), blocks, account)
+		}
+	}
+}
+
+// 降级后的配置都能通过校验，校验失败时不再降级
+func TestDegradedContext(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		ctx := testContext(6, useFlatten)
+		for level := 0; level <= 10; level++ {
+			assert.Nil(t, degradedContext(ctx, level).Config.CSCTreeConfig.Validate(), level)
+		}
+	}
+	ctx := testContext(6, false)
+	ctx.Config.CSCTreeConfig.RepetitionNum = 0
+	c := degradedContext(ctx, 3).Config.CSCTreeConfig
+	assert.Equal(t, ctx.Config.CSCTreeConfig.BfFalsePositiveRate, c.BfFalsePositiveRate)
+	assert.Equal(t, ctx.Config.CSCTreeConfig.FingerprintSize, c.FingerprintSize)
+}
@@ -0,0 +1,125 @@
+package csctree
+
+import (
+	stdcontext "context"
+	"sort"
+	"time"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/filter/cscsketch"
+)
+
+// 叶子节点的确认方式
+type Source int
+
+const (
+	FROM_HASHMAP_CSCR Source = iota + 1 // 精确的 HashMap CSCR
+	FROM_LEAF_BF                        // 叶子节点对的 BloomFilter
+	FROM_SKETCH_CSCR                    // Sketch CSCR
+	FROM_FLATTEN_CSCR                   // FlattenNode 的 FlattenCSCR
+)
+
+func (s Source) String() string {
+	switch s {
+	case FROM_HASHMAP_CSCR:
+		return "HASHMAP_CSCR"
+	case FROM_LEAF_BF:
+		return "LEAF_BF"
+	case FROM_SKETCH_CSCR:
+		return "SKETCH_CSCR"
+	case FROM_FLATTEN_CSCR:
+		return "FLATTEN_CSCR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// 带来源的查询结果，FalsePositiveProb 为根据路径上各个过滤器的参数估计的误报概率。
+// 嵌入了叶子节点本身，Get、GetWithRange 与 GetWithKLeafs 返回的 Node 都是 *QueryResult
+type QueryResult struct {
+	Node
+	Source            Source
+	FalsePositiveProb float64
+}
+
+// 查询结果的来源以及误报概率，n 不是查询返回的节点时 ok 为 false
+func Provenance(n Node) (source Source, fpProb float64, ok bool) {
+	r, ok := n.(*QueryResult)
+	if !ok {
+		return 0, 0, false
+	}
+	return r.Source, r.FalsePositiveProb, true
+}
+
+func cscrSource(cscr *cscsketch.CSCR) Source {
+	if cscr.CType == cscsketch.HASHMAP {
+		return FROM_HASHMAP_CSCR
+	}
+	return FROM_SKETCH_CSCR
+}
+
+// 路径上任意一步误报都会导致结果误报
+func combineFpProb(p1 float64, p2 float64) float64 {
+	return 1 - (1-p1)*(1-p2)
+}
+
+func withFpProb(qp QueryPlan, fpProb float64, parentFpProb float64) QueryPlan {
+	qp.fpProb = fpProb
+	qp.parentFpProb = parentFpProb
+	return qp
+}
+
+// 与 Get（或 GetWithKLeafs）相同的查询，同时返回每个叶子节点的来源以及误报概率
+func (t *CSCTree) GetWithProvenance(item string) ([]QueryResult, *QueryStats) {
+	return t.searchWithProvenance(item, nil)
+}
+
+// 与 GetWithRange 相同的查询，同时返回每个叶子节点的来源以及误报概率
+func (t *CSCTree) GetWithRangeWithProvenance(item string, start_block int, end_block int) ([]QueryResult, *QueryStats) {
+	return t.searchWithProvenance(item, block.NewBlockRange(start_block, end_block))
+}
+
+func (t *CSCTree) searchWithProvenance(item string, rng *block.BlockRange) ([]QueryResult, *QueryStats) {
+	nodes, stats, _ := t.search(stdcontext.Background(), item, rng)
+	res := make([]QueryResult, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, *n.(*QueryResult))
+	}
+	return res, stats
+}
+
+func (cscForest *CSCForest) GetWithProvenance(item string) ([]QueryResult, *QueryStats) {
+	return cscForest.provenanceAll("GetWithProvenance", func(t *CSCTree) ([]QueryResult, *QueryStats) {
+		return t.GetWithProvenance(item)
+	})
+}
+
+func (cscForest *CSCForest) GetWithRangeWithProvenance(item string, start_block int, end_block int) ([]QueryResult, *QueryStats) {
+	return cscForest.provenanceAll("GetWithRangeWithProvenance", func(t *CSCTree) ([]QueryResult, *QueryStats) {
+		return t.GetWithRangeWithProvenance(item, start_block, end_block)
+	})
+}
+
+func (cscForest *CSCForest) provenanceAll(op string, get func(t *CSCTree) ([]QueryResult, *QueryStats)) ([]QueryResult, *QueryStats) {
+	start_time := time.Now()
+	res := make([]QueryResult, 0)
+	stats := NewQueryStats()
+	for _, t := range cscForest.CSCForest {
+		treeRes, treeStats := get(t)
+		res = append(res, treeRes...)
+		stats.Add(treeStats)
+	}
+	stats.TotalTime = time.Since(start_time)
+	cscForest.Stats.Record(op, stats)
+	return res, stats
+}
+
+// 按误报概率从低到高排序，误报概率相同时按区块顺序
+func SortByConfidence(results []QueryResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].FalsePositiveProb != results[j].FalsePositiveProb {
+			return results[i].FalsePositiveProb < results[j].FalsePositiveProb
+		}
+		return results[i].Node.GetRange().Start < results[j].Node.GetRange().Start
+	})
+}
@@ -0,0 +1,70 @@
+package csctree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWithProvenance(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		forest, truth := buildTestForest(testContext(5, useFlatten), 96, 8)
+		sources := make(map[Source]int)
+		for account, blocks := range truth {
+			expected, _ := forest.Get(account)
+			results, _ := forest.GetWithProvenance(account)
+			nodes := make([]Node, 0, len(results))
+			for _, r := range results {
+				nodes = append(nodes, r.Node)
+				sources[r.Source]++
+				assert.GreaterOrEqual(t, r.FalsePositiveProb, 0.0)
+				assert.Less(t, r.FalsePositiveProb, 1.0)
+			}
+			assert.Equal(t, blockNumbers(expected), blockNumbers(nodes), account)
+
+			// 真实出现的区块按置信度排序后仍然全部存在
+			SortByConfidence(results)
+			for i := 1; i < len(results); i++ {
+				assert.LessOrEqual(t, results[i-1].FalsePositiveProb, results[i].FalsePositiveProb)
+			}
+			found := make(map[int]bool)
+			for _, r := range results {
+				found[r.Node.GetRange().Start] = true
+			}
+			for _, b := range blocks {
+				assert.True(t, found[b], "%v %v", account, b)
+			}
+		}
+		if useFlatten {
+			assert.Greater(t, sources[FROM_FLATTEN_CSCR], 0)
+		} else {
+			assert.Greater(t, sources[FROM_LEAF_BF], 0)
+		}
+	}
+}
+
+// Get 返回的节点同样记录来源与误报概率；内部节点的 BloomFilter 命中计入误报概率
+func TestGetCarriesProvenance(t *testing.T) {
+	ctx := testContext(5, false)
+	// 所有 CSCR 都是 HashMap，HASHMAP_CSCR 的结果只有经过 BloomFilter 时误报概率才大于 0
+	ctx.Config.CSCTreeConfig.SketchLevel = 5
+	forest, truth := buildTestForest(ctx, 96, 8)
+	viaBf := 0
+	for account := range truth {
+		nodes, _ := forest.Get(account)
+		results, _ := forest.GetWithProvenance(account)
+		assert.Equal(t, len(results), len(nodes))
+		for i, n := range nodes {
+			source, fpProb, ok := Provenance(n)
+			assert.True(t, ok)
+			assert.Equal(t, results[i].Source, source)
+			assert.Equal(t, results[i].FalsePositiveProb, fpProb)
+			if source == FROM_HASHMAP_CSCR && fpProb >= ctx.Config.CSCTreeConfig.BfFalsePositiveRate {
+				viaBf++
+			}
+		}
+	}
+	assert.Greater(t, viaBf, 0)
+	_, _, ok := Provenance(forest.CSCForest[0].Root)
+	assert.False(t, ok)
+}
@@ -0,0 +1,136 @@
+package csctree
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// 单次查询的统计信息，每个查询接口都会返回一个新的 QueryStats
+type QueryStats struct {
+	RootNodes         int           `json:"root_nodes"` // 访问的各类节点数量
+	InternalNodes     int           `json:"internal_nodes"`
+	LeafNodes         int           `json:"leaf_nodes"`
+	FlattenNodes      int           `json:"flatten_nodes"`
+	BfProbes          int           `json:"bf_probes"`
+	BfHits            int           `json:"bf_hits"`
+	CSCRProbes        int           `json:"cscr_probes"` // 包括 FlattenCSCR
+	PartitionsScanned int           `json:"partitions_scanned"`
+	Backtracks        int           `json:"backtracks"`
+	LeavesReturned    int           `json:"leaves_returned"`
+	BFTime            time.Duration `json:"bf_time"`
+	CSCRTime          time.Duration `json:"cscr_time"`
+	TotalTime         time.Duration `json:"total_time"`
+}
+
+func NewQueryStats() *QueryStats {
+	return &QueryStats{}
+}
+
+func (s *QueryStats) Add(s2 *QueryStats) {
+	s.RootNodes += s2.RootNodes
+	s.InternalNodes += s2.InternalNodes
+	s.LeafNodes += s2.LeafNodes
+	s.FlattenNodes += s2.FlattenNodes
+	s.BfProbes += s2.BfProbes
+	s.BfHits += s2.BfHits
+	s.CSCRProbes += s2.CSCRProbes
+	s.PartitionsScanned += s2.PartitionsScanned
+	s.Backtracks += s2.Backtracks
+	s.LeavesReturned += s2.LeavesReturned
+	s.BFTime += s2.BFTime
+	s.CSCRTime += s2.CSCRTime
+	s.TotalTime += s2.TotalTime
+}
+
+func (s *QueryStats) NodesVisited() int {
+	return s.RootNodes + s.InternalNodes + s.LeafNodes + s.FlattenNodes
+}
+
+func (s *QueryStats) visit(n Node) {
+	switch n.(type) {
+	case *RootNode:
+		s.RootNodes++
+	case *InternalNode:
+		s.InternalNodes++
+	case *LeafNode:
+		s.LeafNodes++
+	case *FlattenNode:
+		s.FlattenNodes++
+	}
+}
+
+// 延迟直方图的桶上界，最后一个桶没有上界
+var LatencyBuckets = []time.Duration{
+	10 * time.Microsecond, 50 * time.Microsecond, 100 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second,
+}
+
+type LatencyHistogram struct {
+	Counts []int         `json:"counts"` // Counts[i] 为延迟不超过 LatencyBuckets[i] 的查询数，最后一个为超过所有上界的查询数
+	Count  int           `json:"count"`
+	Sum    time.Duration `json:"sum"`
+	Max    time.Duration `json:"max"`
+}
+
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{
+		Counts: make([]int, len(LatencyBuckets)+1),
+	}
+}
+
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	h.Max = max(h.Max, d)
+}
+
+// 森林级别的统计汇总，记录所有查询的累计计数以及每种查询的延迟直方图，可以被多个 goroutine 同时使用
+type StatsAggregator struct {
+	mu        sync.Mutex
+	Queries   int                          `json:"queries"`
+	Total     *QueryStats                  `json:"total"`
+	Latencies map[string]*LatencyHistogram `json:"latencies"`
+}
+
+func NewStatsAggregator() *StatsAggregator {
+	return &StatsAggregator{
+		Total:     NewQueryStats(),
+		Latencies: make(map[string]*LatencyHistogram),
+	}
+}
+
+// 记录一次名为 op 的查询
+func (a *StatsAggregator) Record(op string, s *QueryStats) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Queries++
+	a.Total.Add(s)
+	h, ok := a.Latencies[op]
+	if !ok {
+		h = NewLatencyHistogram()
+		a.Latencies[op] = h
+	}
+	h.Observe(s.TotalTime)
+}
+
+func (a *StatsAggregator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Queries = 0
+	a.Total = NewQueryStats()
+	a.Latencies = make(map[string]*LatencyHistogram)
+}
+
+func (a *StatsAggregator) JSON() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	data, _ := json.MarshalIndent(a, "", "  ")
+	return string(data)
+}
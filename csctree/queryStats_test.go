@@ -0,0 +1,57 @@
+package csctree
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryStats(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		forest, truth := buildTestForest(testContext(5, useFlatten), 96, 9)
+		queries := 0
+		for account := range truth {
+			nodes, stats := forest.Get(account)
+			queries++
+			assert.Equal(t, len(nodes), stats.LeavesReturned)
+			assert.LessOrEqual(t, stats.BfHits, stats.BfProbes)
+			assert.Greater(t, stats.NodesVisited(), 0)
+			assert.Greater(t, stats.RootNodes, 0)
+			assert.GreaterOrEqual(t, stats.TotalTime, stats.BFTime)
+		}
+		assert.Equal(t, queries, forest.Stats.Queries)
+		if useFlatten {
+			assert.Greater(t, forest.Stats.Total.FlattenNodes, 0)
+		}
+		assert.Equal(t, queries, forest.Stats.Latencies["Get"].Count)
+
+		var decoded map[string]any
+		assert.Nil(t, json.Unmarshal([]byte(forest.Stats.JSON()), &decoded))
+		forest.Stats.Reset()
+		assert.Equal(t, 0, forest.Stats.Queries)
+	}
+}
+
+func TestStatsAggregatorConcurrent(t *testing.T) {
+	forest, _ := buildTestForest(testContext(5, false), 64, 9)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			forest.Stats.Record("Get", &QueryStats{LeavesReturned: 1})
+		}()
+	}
+	wg.Wait()
+	_, stats := forest.GetMultiThread("0x0000")
+	assert.Equal(t, 9, forest.Stats.Queries)
+	assert.Equal(t, 8+stats.LeavesReturned, forest.Stats.Total.LeavesReturned)
+	h := forest.Stats.Latencies["Get"]
+	sum := 0
+	for _, c := range h.Counts {
+		sum += c
+	}
+	assert.Equal(t, h.Count, sum)
+}
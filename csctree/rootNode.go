@@ -53,7 +53,7 @@ func (r *RootNode) SetSenderSet(as *block.AccountSet) {
 	r.SenderSet = as
 }
 
-func (r *RootNode) SetBloomFilter(bf *basicfilter.BloomFilter) {
+func (r *RootNode) SetFilter(f basicfilter.MembershipFilter) {
 }
 
 func (r *RootNode) SetCSCR(cscr *cscsketch.CSCR) {
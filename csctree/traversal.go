@@ -0,0 +1,429 @@
+package csctree
+
+import (
+	"container/heap"
+	"strconv"
+	"time"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/filter/cscsketch"
+)
+
+// 单个 QueryPlan 的展开逻辑，Get、GetWithRange、GetWithKLeafs 以及其他查询共用：
+// 新的 QueryPlan 通过 push 交给调用方，确认的叶子节点通过 emit 交给调用方，
+// 由调用方决定遍历顺序（BFS、按区块顺序等）以及何时停止
+type traversal struct {
+	t    *CSCTree
+	item string
+	rng  *block.BlockRange // 为 nil 时不做范围裁剪
+	push func(qp QueryPlan)
+	emit func(n Node)
+	// 可选，同时记录叶子节点的来源以及误报概率
+	record func(r QueryResult)
+
+	trace *TraceNode // 当前展开的 QueryPlan 的记录，为 nil 时不记录
+	stats *QueryStats
+}
+
+func (t *CSCTree) newTraversal(item string, rng *block.BlockRange) *traversal {
+	// 清除缓存
+	t.CscCacheList.Clear()
+	return &traversal{
+		t:     t,
+		item:  item,
+		rng:   rng,
+		stats: NewQueryStats(),
+	}
+}
+
+func (tr *traversal) inRange(n Node) bool {
+	return tr.rng == nil || n.GetRange().Intersect(tr.rng)
+}
+
+// 节点与其兄弟节点中任意一个与查询范围有重合
+func (tr *traversal) pairInRange(n Node) bool {
+	if tr.inRange(n) {
+		return true
+	}
+	sibling := n.GetSiblingNode()
+	return sibling != nil && tr.inRange(sibling)
+}
+
+func (tr *traversal) emitInRange(n Node, source Source, fpProb float64) {
+	if tr.inRange(n) {
+		tr.stats.LeavesReturned++
+		tr.emit(n)
+		if tr.record != nil {
+			tr.record(QueryResult{Node: n, Source: source, FalsePositiveProb: fpProb})
+		}
+	}
+}
+
+// 检查节点的过滤器
+func (tr *traversal) checkBloomFilter(n Node) bool {
+	start_time := time.Now()
+	var hit bool
+	switch n := n.(type) {
+	case *InternalNode:
+		hit = n.Filter.Contains(tr.item)
+	case *LeafNode:
+		hit = n.Filter.Contains(tr.item)
+	case *FlattenNode:
+		hit = n.Filter.Contains(tr.item)
+	}
+	tr.stats.BFTime += time.Since(start_time)
+	tr.stats.BfProbes++
+	if hit {
+		tr.stats.BfHits++
+	}
+	if tr.trace != nil {
+		tr.trace.BfHit = &hit
+	}
+	return hit
+}
+
+// 与查询范围没有重合的孩子不会返回结果，但不做范围裁剪时它会在 BloomFilter 与 CSCR 都未命中时回溯到父节点，
+// 这里做同样的检查，否则父节点 BloomFilter 假阳时范围内的结果可能漏报，且结果会随查询范围变化
+func (tr *traversal) prunedNeedsBacktrack(n Node) bool {
+	trace := tr.trace
+	tr.trace = nil
+	defer func() { tr.trace = trace }()
+	return !tr.checkBloomFilter(n) && len(tr.checkCSCR(n)) == 0
+}
+
+// 检查节点的 CSCR，返回其中记录的节点 id
+func (tr *traversal) checkCSCR(n Node) []int {
+	start_time := time.Now()
+	cscr_res, candidates := cscrOf(n).GetWithCacheCount(tr.item, tr.t.CscCacheList)
+	tr.stats.CSCRTime += time.Since(start_time)
+	tr.stats.CSCRProbes++
+	tr.stats.PartitionsScanned += candidates
+	nids := make([]int, 0, len(cscr_res))
+	for _, nodeId := range cscr_res {
+		nid, _ := strconv.Atoi(nodeId)
+		nids = append(nids, nid)
+	}
+	if tr.trace != nil {
+		tr.trace.CSCRCandidates = nids
+	}
+	return nids
+}
+
+func cscrOf(n Node) *cscsketch.CSCR {
+	switch n := n.(type) {
+	case *InternalNode:
+		return n.CSCR
+	case *LeafNode:
+		return n.CSCR
+	case *FlattenNode:
+		return n.CSCR
+	}
+	return cscsketch.NewEmptyCSCR()
+}
+
+// 检查 FlattenNode 的 FlattenCSCR
+func (tr *traversal) searchFlattenCSCR(n *FlattenNode, item string) []Node {
+	res := tr.t.searchFlattenCSCR(n, item, tr.stats)
+	if tr.trace != nil {
+		for _, leaf := range res {
+			tr.trace.FlattenCSCR = append(tr.trace.FlattenCSCR, describeNode(leaf.GetNid(), leaf))
+		}
+	}
+	return res
+}
+
+// 一个 QueryPlan 展开后的结果
+type planOutcome int
+
+const (
+	outcomeSkipped   planOutcome = iota // 与查询范围没有重合，未做任何检查
+	outcomeRoot                         // RootNode，只将左孩子加入队列
+	outcomeBfHit                        // BloomFilter 命中
+	outcomeCSCRHit                      // BloomFilter 未命中（或被忽略），CSCR 返回了结果
+	outcomeMiss                         // BloomFilter 与 CSCR 都没有结果
+	outcomeBacktrack                    // 父节点的 BloomFilter 假阳，已将父节点重新加入队列
+)
+
+// 展开一个 QueryPlan，并记录统计信息
+func (tr *traversal) expand(qp QueryPlan) planOutcome {
+	outcome := tr.expandPlan(qp)
+	if outcome != outcomeSkipped {
+		tr.stats.visit(qp.N)
+	}
+	if outcome == outcomeBacktrack {
+		tr.stats.Backtracks++
+	}
+	return outcome
+}
+
+func (tr *traversal) expandPlan(qp QueryPlan) planOutcome {
+	switch n := qp.N.(type) {
+	// 如果是 RootNode，则将左孩子加入队列（只需要加入一个节点，另一个可以通过 sibling 指针获取）
+	case *RootNode:
+		if !tr.inRange(n) {
+			return outcomeSkipped
+		}
+		tr.push(NewQueryPlan(n.LeftChild, nil, false, false))
+		return outcomeRoot
+	case *InternalNode:
+		// 如果当前结点和兄弟节点的 range 都和查询范围没有重合，则没必要检查
+		if !tr.pairInRange(n) {
+			return outcomeSkipped
+		}
+		if tr.checkBloomFilter(n) && !qp.IgnoreBfCheck {
+			// 将左孩子和兄弟节点的左孩子加入队列
+			backtrack := false
+			bfFpProb := combineFpProb(qp.fpProb, n.Filter.FalsePositiveRate())
+			if tr.pairInRange(n.LeftChild) {
+				tr.push(withFpProb(NewQueryPlan(n.LeftChild, n, true, false), bfFpProb, qp.fpProb))
+			} else {
+				backtrack = tr.prunedNeedsBacktrack(n.LeftChild)
+			}
+			siblingLeftChild := n.GetSiblingNode().GetLeftChild()
+			if tr.pairInRange(siblingLeftChild) {
+				tr.push(withFpProb(NewQueryPlan(siblingLeftChild, n, true, false), bfFpProb, qp.fpProb))
+			} else {
+				backtrack = tr.prunedNeedsBacktrack(siblingLeftChild) || backtrack
+			}
+			if backtrack {
+				tr.push(withFpProb(NewQueryPlan(n, nil, false, true), qp.fpProb, 0))
+			}
+			return outcomeBfHit
+		}
+		cscr_res := tr.checkCSCR(n)
+		source, fpProb := cscrSource(n.CSCR), combineFpProb(qp.fpProb, n.CSCR.FalsePositiveRate())
+		if len(cscr_res) == 0 && qp.IsPushedByBf {
+			// 如果是由 BloomFilter 推入的节点，且 CSCR 为空，说明布隆过滤器假阳了，需要回溯检查上一层的 csc
+			tr.push(withFpProb(NewQueryPlan(qp.ParentNode, nil, false, true), qp.parentFpProb, 0))
+			return outcomeBacktrack
+		}
+		for _, nid := range cscr_res {
+			// 向下遍历树，找到符合条件的节点
+			foundNode := tr.t.findNodeById(n, nid)
+			if tr.trace != nil {
+				tr.trace.FoundNodes = append(tr.trace.FoundNodes, describeNode(nid, foundNode))
+			}
+			if foundNode == nil {
+				continue
+			}
+			switch foundNode.GetNodeType() {
+			case LEAF:
+				tr.emitInRange(foundNode, source, fpProb)
+			case FLATTEN:
+				if tr.inRange(foundNode) {
+					flattenNode := foundNode.(*FlattenNode)
+					flattenFpProb := combineFpProb(fpProb, flattenNode.FlattenCSCR.FalsePositiveRate())
+					for _, leaf := range tr.searchFlattenCSCR(flattenNode, tr.item) {
+						tr.emitInRange(leaf, FROM_FLATTEN_CSCR, flattenFpProb)
+					}
+				}
+			default:
+				if tr.pairInRange(foundNode.GetLeftChild()) {
+					tr.push(withFpProb(NewQueryPlan(foundNode.GetLeftChild(), n, false, false), fpProb, qp.fpProb))
+				}
+			}
+		}
+		return cscrOutcome(cscr_res)
+	case *LeafNode:
+		if !tr.pairInRange(n) {
+			return outcomeSkipped
+		}
+		// 先检查是否在 BloomFilter 中，如果在，则将其与兄弟节点加入结果
+		if tr.checkBloomFilter(n) {
+			fpProb := combineFpProb(qp.fpProb, n.Filter.FalsePositiveRate())
+			tr.emitInRange(n, FROM_LEAF_BF, fpProb)
+			tr.emitInRange(n.GetSiblingNode(), FROM_LEAF_BF, fpProb)
+			return outcomeBfHit
+		}
+		cscr_res := tr.checkCSCR(n)
+		source, fpProb := cscrSource(n.CSCR), combineFpProb(qp.fpProb, n.CSCR.FalsePositiveRate())
+		if len(cscr_res) == 0 && qp.IsPushedByBf {
+			// 如果是由 BloomFilter 推入的节点，且 CSCR 为空，则说明父节点的 bf 假阳了
+			tr.push(withFpProb(NewQueryPlan(qp.ParentNode, nil, false, true), qp.parentFpProb, 0))
+			return outcomeBacktrack
+		}
+		for _, nid := range cscr_res {
+			// 由于已经是叶子节点，所以不需要向下遍历
+			if n.GetNid() == nid {
+				tr.emitInRange(n, source, fpProb)
+			} else {
+				tr.emitInRange(n.GetSiblingNode(), source, fpProb)
+			}
+		}
+		return cscrOutcome(cscr_res)
+	case *FlattenNode:
+		if !tr.pairInRange(n) {
+			return outcomeSkipped
+		}
+		sibling := n.GetSiblingNode().(*FlattenNode)
+		if tr.checkBloomFilter(n) && !qp.IgnoreBfCheck {
+			left_res := tr.searchFlattenCSCR(n, tr.item)
+			right_res := tr.searchFlattenCSCR(sibling, tr.item)
+			// 两个 FlattenCSCR 中有一个为空，说明 FlattenNode 的 BloomFilter 假阳了，重新检查自身的 CSCR
+			if (len(left_res) == 0 || len(right_res) == 0) && qp.IsPushedByBf {
+				tr.push(withFpProb(NewQueryPlan(qp.N, nil, false, true), qp.fpProb, qp.parentFpProb))
+				return outcomeBacktrack
+			}
+			bfFpProb := combineFpProb(qp.fpProb, n.Filter.FalsePositiveRate())
+			for _, leaf := range left_res {
+				tr.emitInRange(leaf, FROM_FLATTEN_CSCR, combineFpProb(bfFpProb, n.FlattenCSCR.FalsePositiveRate()))
+			}
+			for _, leaf := range right_res {
+				tr.emitInRange(leaf, FROM_FLATTEN_CSCR, combineFpProb(bfFpProb, sibling.FlattenCSCR.FalsePositiveRate()))
+			}
+			return outcomeBfHit
+		}
+		cscr_res := tr.checkCSCR(n)
+		source, fpProb := cscrSource(n.CSCR), combineFpProb(qp.fpProb, n.CSCR.FalsePositiveRate())
+		if len(cscr_res) == 0 && qp.IsPushedByBf {
+			tr.push(withFpProb(NewQueryPlan(qp.ParentNode, nil, false, true), qp.parentFpProb, 0))
+			return outcomeBacktrack
+		}
+		for _, nid := range cscr_res {
+			// 如果 nodeId 为 FlattenNode 的 id，需要进一步查 FlattenCSCR
+			if nid == n.GetNid() || nid == sibling.GetNid() {
+				target := n
+				if nid == sibling.GetNid() {
+					target = sibling
+				}
+				if tr.inRange(target) {
+					flattenFpProb := combineFpProb(fpProb, target.FlattenCSCR.FalsePositiveRate())
+					for _, leaf := range tr.searchFlattenCSCR(target, tr.item) {
+						tr.emitInRange(leaf, FROM_FLATTEN_CSCR, flattenFpProb)
+					}
+				}
+				continue
+			}
+			// 如果 nodeId 为叶子节点的 id，直接返回（需要判断是左节点还是右节点）
+			if leaf := n.GetChildById(nid); leaf != nil {
+				tr.emitInRange(leaf, source, fpProb)
+			} else if leaf := sibling.GetChildById(nid); leaf != nil {
+				tr.emitInRange(leaf, source, fpProb)
+			}
+		}
+		return cscrOutcome(cscr_res)
+	}
+	return outcomeSkipped
+}
+
+func cscrOutcome(cscr_res []int) planOutcome {
+	if len(cscr_res) == 0 {
+		return outcomeMiss
+	}
+	return outcomeCSCRHit
+}
+
+// QueryPlan 展开后可能返回的叶子节点所在的范围（节点与兄弟节点范围的并集）
+func planRange(qp QueryPlan) *block.BlockRange {
+	r := qp.N.GetRange()
+	sibling := qp.N.GetSiblingNode()
+	if sibling == nil {
+		return r
+	}
+	sr := sibling.GetRange()
+	return block.NewBlockRange(min(r.Start, sr.Start), max(r.End, sr.End))
+}
+
+// 按区块顺序遍历时堆中的元素，leaf 不为 nil 时表示已确认的叶子节点，否则表示待展开的 QueryPlan
+type walkItem struct {
+	key  int
+	seq  int
+	leaf Node
+	qp   QueryPlan
+}
+
+type walkHeap []walkItem
+
+func (h walkHeap) Len() int { return len(h) }
+
+func (h walkHeap) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key < h[j].key
+	}
+	// 键相同时优先返回叶子节点：QueryPlan 只可能产生不早于自身键的叶子节点
+	if (h[i].leaf != nil) != (h[j].leaf != nil) {
+		return h[i].leaf != nil
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h walkHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *walkHeap) Push(x any) { *h = append(*h, x.(walkItem)) }
+
+func (h *walkHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// QueryPlan 展开时可能产生的叶子节点与回溯的范围：由 BloomFilter 推入的节点可能回溯到父节点，
+// 父节点重新展开后可以返回父节点范围内的任意叶子节点；回溯时不再检查 BloomFilter，最多只回溯一层
+func reachRange(qp QueryPlan) *block.BlockRange {
+	if qp.IsPushedByBf && qp.ParentNode != nil {
+		return planRange(NewQueryPlan(qp.ParentNode, nil, false, true))
+	}
+	return planRange(qp)
+}
+
+// 按区块顺序（或逆序）惰性地返回一个 item 所在的叶子节点。
+// 每个 QueryPlan 以其可能产生的叶子节点范围（包括回溯）的起点（逆序时为终点）作为优先级，
+// 展开后推入的 QueryPlan 与叶子节点都不会早于（晚于）该优先级，因此堆顶的叶子节点一定是剩余结果中最早（最晚）的一个
+type orderedWalker struct {
+	tr         *traversal
+	heap       walkHeap
+	descending bool
+	seq        int
+	last       *block.BlockRange // 上一个返回的叶子节点范围，用于去重
+}
+
+func (t *CSCTree) newOrderedWalker(item string, rng *block.BlockRange, descending bool) *orderedWalker {
+	w := &orderedWalker{
+		tr:         t.newTraversal(item, rng),
+		heap:       make(walkHeap, 0),
+		descending: descending,
+	}
+	w.tr.push = func(qp QueryPlan) {
+		w.pushItem(walkItem{key: w.key(reachRange(qp)), qp: qp})
+	}
+	w.tr.emit = func(n Node) {
+		w.pushItem(walkItem{key: w.key(n.GetRange()), leaf: n})
+	}
+	if !t.IsEmpty() {
+		w.tr.push(NewQueryPlan(t.Root, nil, false, false))
+	}
+	return w
+}
+
+func (w *orderedWalker) key(r *block.BlockRange) int {
+	if w.descending {
+		return -r.End
+	}
+	return r.Start
+}
+
+func (w *orderedWalker) pushItem(item walkItem) {
+	item.seq = w.seq
+	w.seq++
+	heap.Push(&w.heap, item)
+}
+
+// 返回下一个叶子节点，遍历结束时返回 nil
+func (w *orderedWalker) Next() Node {
+	for w.heap.Len() > 0 {
+		item := heap.Pop(&w.heap).(walkItem)
+		if item.leaf == nil {
+			w.tr.expand(item.qp)
+			continue
+		}
+		// 同一个叶子节点可能被多次确认（例如回溯），按顺序返回时只需要和上一个结果比较
+		r := item.leaf.GetRange()
+		if w.last != nil && ((!w.descending && r.Start <= w.last.Start) || (w.descending && r.End >= w.last.End)) {
+			continue
+		}
+		w.last = r
+		return item.leaf
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package csctree
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/filter/basicfilter"
+	"github.com/liuys-dase/csc-tree/filter/cscsketch"
+)
+
+// 统计的组件类型，节点过滤器以其类型名（basicfilter.FilterTypes）作为组件类型
+const (
+	COMPONENT_BF           = basicfilter.FILTER_BLOOM
+	COMPONENT_CSCR         = "CSCR"
+	COMPONENT_FLATTEN_CSCR = "FLATTEN_CSCR"
+)
+
+// 某一层、某一类节点上某一种组件的内存与利用率统计；兄弟节点共享的 BloomFilter 和 CSCR 只统计一次
+type ComponentStats struct {
+	Level            int     `json:"level"`
+	NodeType         string  `json:"node_type"`
+	Component        string  `json:"component"`
+	Structures       int     `json:"structures"`        // 组件（去重后）的数量
+	Elements         int     `json:"elements"`          // BF 为加入的元素数，HASHMAP 为 (账户, 节点) 对数，Sketch 为每个 CSC 平均存放的指纹数
+	Bits             int     `json:"bits"`              // BF 的位数或 CSC bucket 的位数，HASHMAP 为 0
+	PartitionEntries int     `json:"partition_entries"` // 所有 CSC 分区中记录的节点 id 数量
+	HashMapEntries   int     `json:"hashmap_entries"`   // HASHMAP 中的账户数量
+	Doubles          int     `json:"doubles"`           // CSC 扩容（翻倍）的次数
+	Slots            int     `json:"slots"`             // CSC 的 slot 总数
+	UsedSlots        int     `json:"used_slots"`
+	Utilization      float64 `json:"utilization"` // UsedSlots / Slots，BF 为置位比例
+	setBits          int
+}
+
+func (c *ComponentStats) add(c2 *ComponentStats) {
+	c.Structures += c2.Structures
+	c.Elements += c2.Elements
+	c.Bits += c2.Bits
+	c.PartitionEntries += c2.PartitionEntries
+	c.HashMapEntries += c2.HashMapEntries
+	c.Doubles += c2.Doubles
+	c.Slots += c2.Slots
+	c.UsedSlots += c2.UsedSlots
+	c.setBits += c2.setBits
+	c.updateUtilization()
+}
+
+func (c *ComponentStats) updateUtilization() {
+	c.Utilization = 0
+	if c.Component == COMPONENT_BF {
+		if c.Bits != 0 {
+			c.Utilization = math.Round(float64(c.setBits)/float64(c.Bits)*100) / 100
+		}
+	} else if c.Slots != 0 {
+		c.Utilization = math.Round(float64(c.UsedSlots)/float64(c.Slots)*100) / 100
+	}
+}
+
+func (c *ComponentStats) csvRecord() []string {
+	return []string{
+		strconv.Itoa(c.Level),
+		c.NodeType,
+		c.Component,
+		strconv.Itoa(c.Structures),
+		strconv.Itoa(c.Elements),
+		strconv.Itoa(c.Bits),
+		strconv.Itoa(c.PartitionEntries),
+		strconv.Itoa(c.HashMapEntries),
+		strconv.Itoa(c.Doubles),
+		strconv.Itoa(c.Slots),
+		strconv.Itoa(c.UsedSlots),
+		strconv.FormatFloat(c.Utilization, 'f', 2, 64),
+	}
+}
+
+var componentStatsHeader = []string{
+	"level", "node_type", "component", "structures", "elements", "bits",
+	"partition_entries", "hashmap_entries", "doubles", "slots", "used_slots", "utilization",
+}
+
+// 节点过滤器的统计，Component 为过滤器的类型名；只有 BloomFilter 统计置位比例
+func filterStats(f basicfilter.MembershipFilter) *ComponentStats {
+	c := &ComponentStats{Component: f.Type(), Structures: 1, Elements: f.Count(), Bits: f.GetBitSize() * 8}
+	if bf, ok := f.(*basicfilter.BloomFilter); ok {
+		c.Bits = bf.M
+		for _, bit := range bf.BitArray {
+			if bit {
+				c.setBits++
+			}
+		}
+	}
+	return c
+}
+
+func cscrStats(cscr *cscsketch.CSCR, component string) *ComponentStats {
+	c := &ComponentStats{Component: component, Structures: 1, Doubles: cscr.DoubleCount}
+	if cscr.CType == cscsketch.HASHMAP {
+		c.HashMapEntries = len(cscr.HashMap)
+		for _, nids := range cscr.HashMap {
+			c.Elements += len(nids)
+		}
+		return c
+	}
+	c.Elements = cscr.GetUtilizationCount()
+	for _, csc := range cscr.CSCs {
+		c.Bits += csc.NumBuckets * csc.SlotNum * csc.FingerprintSize
+		c.Slots += csc.NumBuckets * csc.SlotNum
+		c.UsedSlots += csc.Utilization_count
+		for _, par := range csc.Partitions.Partitions {
+			c.PartitionEntries += len(par.Blocks)
+		}
+	}
+	return c
+}
+
+// 一棵 CSCTree 的统计报告
+type TreeStats struct {
+	Range        *block.BlockRange     `json:"range"`
+	Sealed       bool                  `json:"sealed"`        // 根节点已生成，不再写入
+	DegradeLevel int                   `json:"degrade_level"` // 因内存限制降级的次数
+	Config       *config.CSCTreeConfig `json:"config"`        // 构建该 CSCTree 时使用的配置
+	Components   []*ComponentStats     `json:"components"`
+	Totals       []*ComponentStats     `json:"totals"`    // 按组件类型汇总，Level 为 0，NodeType 为 ALL
+	Anomalies    []*Anomaly            `json:"anomalies"` // 负载异常的 bucket
+}
+
+// 统计 CSCTree 中每一层、每一类节点的过滤器、CSCR 与 FlattenCSCR
+func (t *CSCTree) TreeStats() *TreeStats {
+	ts := &TreeStats{DegradeLevel: t.DegradeLevel, Components: make([]*ComponentStats, 0), Totals: make([]*ComponentStats, 0), Anomalies: t.Anomalies}
+	if ts.Anomalies == nil {
+		ts.Anomalies = make([]*Anomaly, 0)
+	}
+	if t.Context != nil {
+		// 报告中不输出 HashKey
+		ts.Config = t.Context.Config.CSCTreeConfig.Redacted()
+	}
+	if t.Root == nil {
+		return ts
+	}
+	ts.Range = t.Root.GetRange()
+	_, ts.Sealed = t.Root.(*RootNode)
+	rows := make(map[string]*ComponentStats)
+	seen := make(map[any]bool)
+	collect := func(n Node, c *ComponentStats) {
+		c.Level = n.GetLevel()
+		c.NodeType = n.GetNodeType().String()
+		key := strconv.Itoa(c.Level) + "," + c.NodeType + "," + c.Component
+		if row, ok := rows[key]; ok {
+			row.add(c)
+			return
+		}
+		c.updateUtilization()
+		rows[key] = c
+	}
+	visit := func(n Node, f basicfilter.MembershipFilter, cscr *cscsketch.CSCR) {
+		if f != nil && !f.IsEmpty() && !seen[f] {
+			seen[f] = true
+			collect(n, filterStats(f))
+		}
+		if cscr != nil && !cscr.IsEmpty() && !seen[cscr] {
+			seen[cscr] = true
+			collect(n, cscrStats(cscr, COMPONENT_CSCR))
+		}
+	}
+	for _, node := range t.BFS() {
+		switch n := node.(type) {
+		case *InternalNode:
+			visit(n, n.Filter, n.CSCR)
+		case *LeafNode:
+			visit(n, n.Filter, n.CSCR)
+		case *FlattenNode:
+			visit(n, n.Filter, n.CSCR)
+			if n.FlattenCSCR != nil && !n.FlattenCSCR.IsEmpty() && !seen[n.FlattenCSCR] {
+				seen[n.FlattenCSCR] = true
+				collect(n, cscrStats(n.FlattenCSCR, COMPONENT_FLATTEN_CSCR))
+			}
+			for _, child := range n.Children {
+				visit(child, child.Filter, child.CSCR)
+			}
+		}
+	}
+	for _, row := range rows {
+		ts.Components = append(ts.Components, row)
+	}
+	sortComponentStats(ts.Components)
+	ts.Totals = summarize(ts.Components)
+	return ts
+}
+
+// 按层级、节点类型、组件类型排序
+func sortComponentStats(rows []*ComponentStats) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Level != rows[j].Level {
+			return rows[i].Level < rows[j].Level
+		}
+		if rows[i].NodeType != rows[j].NodeType {
+			return rows[i].NodeType < rows[j].NodeType
+		}
+		return rows[i].Component < rows[j].Component
+	})
+}
+
+// 按组件类型汇总
+func summarize(rows []*ComponentStats) []*ComponentStats {
+	totals := make([]*ComponentStats, 0)
+	components := append(basicfilter.FilterTypes(), COMPONENT_CSCR, COMPONENT_FLATTEN_CSCR)
+	for _, component := range components {
+		total := &ComponentStats{NodeType: "ALL", Component: component}
+		for _, row := range rows {
+			if row.Component == component {
+				total.add(row)
+			}
+		}
+		if total.Structures > 0 {
+			totals = append(totals, total)
+		}
+	}
+	return totals
+}
+
+// 返回指定组件的汇总，不存在时返回空的统计
+func (ts *TreeStats) Total(component string) *ComponentStats {
+	for _, total := range ts.Totals {
+		if total.Component == component {
+			return total
+		}
+	}
+	return &ComponentStats{NodeType: "ALL", Component: component}
+}
+
+func (ts *TreeStats) JSON() string {
+	data, _ := json.MarshalIndent(ts, "", "  ")
+	return string(data)
+}
+
+func (ts *TreeStats) CSV() string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write(componentStatsHeader)
+	for _, row := range ts.Components {
+		w.Write(row.csvRecord())
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// 森林中每棵 CSCTree 的统计报告以及整体汇总
+type ForestStats struct {
+	Trees         []*TreeStats      `json:"trees"`
+	Totals        []*ComponentStats `json:"totals"`
+	MemoryLimit   int               `json:"memory_limit"`   // 0 表示不限制
+	MemoryUsage   int               `json:"memory_usage"`   // 估计的内存占用（字节）
+	DegradedTrees []int             `json:"degraded_trees"` // 使用降级配置构建的 CSCTree 的下标
+	Anomalies     int               `json:"anomalies"`      // 所有 CSCTree 中负载异常的 bucket 数量
+}
+
+func (cscForest *CSCForest) ForestStats() *ForestStats {
+	fs := &ForestStats{
+		Trees:         make([]*TreeStats, 0),
+		MemoryLimit:   cscForest.MemoryLimit,
+		MemoryUsage:   cscForest.MemoryUsage(),
+		DegradedTrees: make([]int, 0),
+	}
+	rows := make([]*ComponentStats, 0)
+	for i, t := range cscForest.CSCForest {
+		ts := t.TreeStats()
+		fs.Trees = append(fs.Trees, ts)
+		rows = append(rows, ts.Totals...)
+		if ts.DegradeLevel > 0 {
+			fs.DegradedTrees = append(fs.DegradedTrees, i)
+		}
+		fs.Anomalies += len(ts.Anomalies)
+	}
+	fs.Totals = summarize(rows)
+	return fs
+}
+
+func (fs *ForestStats) Total(component string) *ComponentStats {
+	return (&TreeStats{Totals: fs.Totals}).Total(component)
+}
+
+func (fs *ForestStats) JSON() string {
+	data, _ := json.MarshalIndent(fs, "", "  ")
+	return string(data)
+}
+
+// 每一行额外带上 CSCTree 的下标以及降级次数
+func (fs *ForestStats) CSV() string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write(append(append([]string{"tree"}, componentStatsHeader...), "degrade_level"))
+	for i, ts := range fs.Trees {
+		for _, row := range ts.Components {
+			w.Write(append(append([]string{strconv.Itoa(i)}, row.csvRecord()...), strconv.Itoa(ts.DegradeLevel)))
+		}
+	}
+	w.Flush()
+	return sb.String()
+}
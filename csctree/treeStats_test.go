@@ -0,0 +1,51 @@
+package csctree
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeStats(t *testing.T) {
+	forest, _ := buildTestForest(testContext(5, false), 96, 1)
+	for _, tree := range forest.CSCForest {
+		if tree.IsEmpty() {
+			continue
+		}
+		ts := tree.TreeStats()
+		bf := ts.Total(COMPONENT_BF)
+		cscr := ts.Total(COMPONENT_CSCR)
+		// GetBitSize 以字节为单位，且每个组件单独取整
+		bytes := float64(bf.Bits+cscr.Bits) / 8
+		assert.InDelta(t, bytes, float64(tree.GetBitSize()), float64(bf.Structures+cscr.Structures))
+		assert.True(t, cscr.Elements > 0)
+		assert.True(t, cscr.Utilization > 0 && cscr.Utilization <= 1)
+	}
+	fs := forest.ForestStats()
+	assert.Equal(t, len(forest.CSCForest), len(fs.Trees))
+	assert.True(t, fs.Trees[0].Sealed)
+	assert.False(t, fs.Trees[len(fs.Trees)-1].Sealed)
+	rows := 0
+	for _, ts := range fs.Trees {
+		rows += len(ts.Components)
+	}
+	lines := strings.Split(strings.TrimSpace(fs.CSV()), "\n")
+	assert.Equal(t, rows+1, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "tree,level,node_type,component,"))
+	var decoded ForestStats
+	assert.Nil(t, json.Unmarshal([]byte(fs.JSON()), &decoded))
+	assert.Equal(t, fs.Total(COMPONENT_BF).Bits, decoded.Total(COMPONENT_BF).Bits)
+}
+
+func TestTreeStatsWithFlatten(t *testing.T) {
+	forest, _ := buildTestForest(testContext(5, true), 96, 1)
+	fs := forest.ForestStats()
+	assert.True(t, fs.Total(COMPONENT_FLATTEN_CSCR).Structures > 0)
+	for _, ts := range fs.Trees {
+		for _, row := range ts.Components {
+			assert.NotEqual(t, "ROOT", row.NodeType)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package basicfilter
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// 3-wise Binary Fuse Filter（Graf & Lemire, 2022），每个元素约 9 bit，误判率约 1/256，查询只访问 3 个位置。
+// 只能通过 BatchAdd 一次性构建，适合创建后不再变化的兄弟节点交集
+type BinaryFuse8 struct {
+	HashSeed           uint64 // 将元素哈希为 64 位 key 的种子
+	Seed               uint64 // 构建成功时使用的混合种子
+	SegmentLength      uint32
+	SegmentLengthMask  uint32
+	SegmentCount       uint32
+	SegmentCountLength uint32
+	Fingerprints       []uint8
+	ElementNum         int // 去重后的元素数量
+	// 构建失败（概率可以忽略）时所有查询都返回 true，保证不会漏报
+	AllPass bool
+}
+
+const (
+	binaryFuseArity         = 3
+	binaryFuseMaxIterations = 100
+	binaryFuseMaxSegment    = 262144
+)
+
+func newBinaryFuseMembershipFilter(n int, opts *FilterOptions) MembershipFilter {
+	f := &BinaryFuse8{}
+	switch {
+	case opts.HashGroup != nil && len(opts.HashGroup.Seeds) > 0:
+		// 与 BloomFilter 共用（可能由密钥派生的）种子
+		f.HashSeed = opts.HashGroup.Seeds[0]
+	case opts.Rng != nil:
+		f.HashSeed = opts.Rng.Uint64()
+	default:
+		f.HashSeed = rand.Uint64()
+	}
+	f.Seed = f.HashSeed
+	return f
+}
+
+func (f *BinaryFuse8) key(item string) uint64 {
+	d := xxhash.NewWithSeed(f.HashSeed)
+	d.WriteString(item)
+	return d.Sum64()
+}
+
+// 静态过滤器不支持单独加入元素
+func (f *BinaryFuse8) Add(item string) bool {
+	return false
+}
+
+// 使用 items 构建过滤器，已有的内容会被丢弃
+func (f *BinaryFuse8) BatchAdd(items []string) {
+	keys := make([]uint64, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, f.key(item))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	uniq := keys[:0]
+	for i, k := range keys {
+		if i == 0 || k != keys[i-1] {
+			uniq = append(uniq, k)
+		}
+	}
+	f.populate(uniq)
+}
+
+func (f *BinaryFuse8) initializeParameters(size int) {
+	segmentLength := uint32(1) << int(math.Floor(math.Log(float64(size))/math.Log(3.33)+2.25))
+	if segmentLength > binaryFuseMaxSegment {
+		segmentLength = binaryFuseMaxSegment
+	}
+	capacity := 0
+	if size > 1 {
+		sizeFactor := math.Max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(float64(size)))
+		capacity = int(math.Round(float64(size) * sizeFactor))
+	}
+	segmentCount := (capacity+int(segmentLength)-1)/int(segmentLength) - (binaryFuseArity - 1)
+	if segmentCount < 1 {
+		segmentCount = 1
+	}
+	f.SegmentLength = segmentLength
+	f.SegmentLengthMask = segmentLength - 1
+	f.SegmentCount = uint32(segmentCount)
+	f.SegmentCountLength = f.SegmentCount * segmentLength
+	f.Fingerprints = make([]uint8, (segmentCount+binaryFuseArity-1)*int(segmentLength))
+}
+
+// 三个位置分别落在相邻的三个 segment 中
+func (f *BinaryFuse8) getHashFromHash(hash uint64) (uint32, uint32, uint32) {
+	hi, _ := bits.Mul64(hash, uint64(f.SegmentCountLength))
+	h0 := uint32(hi)
+	h1 := h0 + f.SegmentLength
+	h2 := h1 + f.SegmentLength
+	h1 ^= uint32(hash>>18) & f.SegmentLengthMask
+	h2 ^= uint32(hash) & f.SegmentLengthMask
+	return h0, h1, h2
+}
+
+// 通过剥离（peeling）确定每个 key 负责的位置，失败时更换种子重试
+func (f *BinaryFuse8) populate(keys []uint64) {
+	size := len(keys)
+	f.ElementNum = size
+	f.AllPass = false
+	if size == 0 {
+		f.Fingerprints = nil
+		return
+	}
+	f.initializeParameters(size)
+	capacity := len(f.Fingerprints)
+	// t2count 的低 2 位记录该位置是 key 的第几个位置（异或），高 6 位记录 key 的数量
+	t2count := make([]uint8, capacity)
+	t2hash := make([]uint64, capacity)
+	alone := make([]uint32, capacity)
+	reverseOrder := make([]uint64, size)
+	reverseH := make([]uint8, size)
+	rngCounter := f.Seed
+	for iteration := 0; ; iteration++ {
+		if iteration >= binaryFuseMaxIterations {
+			f.AllPass = true
+			return
+		}
+		f.Seed = splitmix64(&rngCounter)
+		for i := range t2count {
+			t2count[i] = 0
+			t2hash[i] = 0
+		}
+		overflow := false
+		for _, key := range keys {
+			hash := mixsplit(key, f.Seed)
+			h0, h1, h2 := f.getHashFromHash(hash)
+			t2count[h0] += 4
+			t2hash[h0] ^= hash
+			t2count[h1] += 4
+			t2count[h1] ^= 1
+			t2hash[h1] ^= hash
+			t2count[h2] += 4
+			t2count[h2] ^= 2
+			t2hash[h2] ^= hash
+			if t2count[h0] < 4 || t2count[h1] < 4 || t2count[h2] < 4 {
+				overflow = true
+			}
+		}
+		if overflow {
+			continue
+		}
+		qsize := 0
+		for i := 0; i < capacity; i++ {
+			if t2count[i]>>2 == 1 {
+				alone[qsize] = uint32(i)
+				qsize++
+			}
+		}
+		stackSize := 0
+		var h012 [5]uint32
+		for qsize > 0 {
+			qsize--
+			index := alone[qsize]
+			if t2count[index]>>2 != 1 {
+				continue
+			}
+			hash := t2hash[index]
+			found := t2count[index] & 3
+			reverseH[stackSize] = found
+			reverseOrder[stackSize] = hash
+			stackSize++
+			h012[0], h012[1], h012[2] = f.getHashFromHash(hash)
+			h012[3], h012[4] = h012[0], h012[1]
+			for j := uint8(1); j <= 2; j++ {
+				other := h012[found+j]
+				if t2count[other]>>2 == 2 {
+					alone[qsize] = other
+					qsize++
+				}
+				t2count[other] -= 4
+				t2count[other] ^= mod3(found + j)
+				t2hash[other] ^= hash
+			}
+		}
+		if stackSize == size {
+			break
+		}
+	}
+	for i := range f.Fingerprints {
+		f.Fingerprints[i] = 0
+	}
+	var h012 [5]uint32
+	for i := size - 1; i >= 0; i-- {
+		hash := reverseOrder[i]
+		found := reverseH[i]
+		h012[0], h012[1], h012[2] = f.getHashFromHash(hash)
+		h012[3], h012[4] = h012[0], h012[1]
+		f.Fingerprints[h012[found]] = uint8(fuseFingerprint(hash)) ^ f.Fingerprints[h012[found+1]] ^ f.Fingerprints[h012[found+2]]
+	}
+}
+
+func (f *BinaryFuse8) Contains(item string) bool {
+	if f.AllPass {
+		return true
+	}
+	if f.IsEmpty() {
+		return false
+	}
+	hash := mixsplit(f.key(item), f.Seed)
+	h0, h1, h2 := f.getHashFromHash(hash)
+	return uint8(fuseFingerprint(hash))^f.Fingerprints[h0]^f.Fingerprints[h1]^f.Fingerprints[h2] == 0
+}
+
+func (f *BinaryFuse8) IsEmpty() bool {
+	return len(f.Fingerprints) == 0 && !f.AllPass
+}
+
+// 字节数
+func (f *BinaryFuse8) GetBitSize() int {
+	return len(f.Fingerprints)
+}
+
+func (f *BinaryFuse8) Count() int {
+	return f.ElementNum
+}
+
+func (f *BinaryFuse8) FalsePositiveRate() float64 {
+	if f.AllPass {
+		return 1
+	}
+	return 1.0 / 256
+}
+
+func (f *BinaryFuse8) Type() string {
+	return FILTER_BINARY_FUSE
+}
+
+// 与 BinaryFuse8 字段相同但没有 MarshalBinary 方法，避免 gob 递归调用
+type binaryFuse8Data BinaryFuse8
+
+func (f *BinaryFuse8) MarshalBinary() ([]byte, error) {
+	return gobEncode((*binaryFuse8Data)(f))
+}
+
+func decodeBinaryFuse8(data []byte) (MembershipFilter, error) {
+	var d binaryFuse8Data
+	if err := gobDecode(data, &d); err != nil {
+		return nil, err
+	}
+	// 空过滤器与 AllPass 不会访问 Fingerprints
+	if len(d.Fingerprints) > 0 && !d.AllPass {
+		segmentLength := uint64(d.SegmentLength)
+		if segmentLength == 0 || segmentLength&(segmentLength-1) != 0 || d.SegmentLengthMask != d.SegmentLength-1 ||
+			uint64(d.SegmentCountLength) != uint64(d.SegmentCount)*segmentLength ||
+			uint64(len(d.Fingerprints)) != (uint64(d.SegmentCount)+binaryFuseArity-1)*segmentLength {
+			return nil, malformed(FILTER_BINARY_FUSE, "%d fingerprints for %d segments of length %d", len(d.Fingerprints), d.SegmentCount, d.SegmentLength)
+		}
+	}
+	f := BinaryFuse8(d)
+	return &f, nil
+}
+
+func mod3(x uint8) uint8 {
+	if x > 2 {
+		x -= 3
+	}
+	return x
+}
+
+func fuseFingerprint(hash uint64) uint64 {
+	return hash ^ (hash >> 32)
+}
+
+func murmur64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func mixsplit(key, seed uint64) uint64 {
+	return murmur64(key + seed)
+}
+
+func splitmix64(seed *uint64) uint64 {
+	*seed += 0x9e3779b97f4a7c15
+	z := *seed
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
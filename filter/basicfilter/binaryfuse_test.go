@@ -0,0 +1,43 @@
+package basicfilter
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryFuse8(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 10, 100, 10000} {
+		items := make([]string, n)
+		for i := range items {
+			items[i] = fmt.Sprintf("0x%06d", i)
+		}
+		f := newBinaryFuseMembershipFilter(n, &FilterOptions{Rng: rand.New(rand.NewSource(int64(n)))})
+		assert.False(t, f.Add(items[0]))
+		// 重复的元素只保留一个
+		f.BatchAdd(append(items, items[0]))
+		assert.Equal(t, n, f.Count())
+		assert.False(t, f.(*BinaryFuse8).AllPass)
+		for _, item := range items {
+			assert.True(t, f.Contains(item), n)
+		}
+	}
+	items := make([]string, 100000)
+	for i := range items {
+		items[i] = fmt.Sprintf("0x%06d", i)
+	}
+	f := newBinaryFuseMembershipFilter(len(items), &FilterOptions{Rng: rand.New(rand.NewSource(1))})
+	f.BatchAdd(items)
+	bitsPerKey := float64(f.GetBitSize()*8) / float64(len(items))
+	// 同样误判率下 BloomFilter 约需要 11.5 bit
+	assert.True(t, bitsPerKey < 10, bitsPerKey)
+	fp := 0
+	for i := 0; i < 100000; i++ {
+		if f.Contains(fmt.Sprintf("0y%06d", i)) {
+			fp++
+		}
+	}
+	assert.InDelta(t, 1.0/256, float64(fp)/100000, 0.002)
+}
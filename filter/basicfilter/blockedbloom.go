@@ -0,0 +1,192 @@
+package basicfilter
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// 每个 block 为 512 bit（一个 cache line）
+const (
+	blockBits  = 512
+	blockWords = blockBits / 64
+)
+
+// 分块布隆过滤器：一个元素的 K 个位都落在同一个 512 bit 的 block 中，
+// 所有位置由一次 64 位哈希派生，查询只访问一个 cache line
+type BlockedBloomFilter struct {
+	Words      []uint64 // 按 block 连续存放的位数组
+	BlockNum   int
+	K          int
+	Fpr        float64 // 目标误判率
+	Seed       uint64
+	ElementNum int // 重复加入时会重复计数
+	// 种子来自 hashGroup 时复用其中缓存的哈希值，同一个元素在逐层查询时只哈希一次
+	hashGroup *BFHashGroup
+}
+
+func NewBlockedBloomFilter(n int, fpr float64, k int, seed uint64) *BlockedBloomFilter {
+	if n == 0 {
+		return &BlockedBloomFilter{K: k, Fpr: fpr, Seed: seed}
+	}
+	blockNum := FindOptimalBlockNum(n, fpr, k)
+	return &BlockedBloomFilter{
+		Words:    make([]uint64, blockNum*blockWords),
+		BlockNum: blockNum,
+		K:        k,
+		Fpr:      fpr,
+		Seed:     seed,
+	}
+}
+
+func newBlockedBloomMembershipFilter(n int, opts *FilterOptions) MembershipFilter {
+	if opts.HashGroup != nil && len(opts.HashGroup.Seeds) > 0 {
+		bf := NewBlockedBloomFilter(n, opts.Fpr, opts.HashFuncNum, opts.HashGroup.Seeds[0])
+		bf.hashGroup = opts.HashGroup
+		return bf
+	}
+	var seed uint64
+	if opts.Rng != nil {
+		seed = opts.Rng.Uint64()
+	} else {
+		seed = rand.Uint64()
+	}
+	return NewBlockedBloomFilter(n, opts.Fpr, opts.HashFuncNum, seed)
+}
+
+// 以 Seed 为种子的 64 位哈希，hashGroup 的第一个哈希函数使用相同的种子
+func (bf *BlockedBloomFilter) hash(item string) uint64 {
+	if bf.hashGroup != nil {
+		return bf.hashGroup.Write(item)[0]
+	}
+	h := xxhash.NewWithSeed(bf.Seed)
+	h.WriteString(item)
+	return h.Sum64()
+}
+
+// 每个位置需要 9 bit，一个 64 位的值可以提供 7 个位置
+const positionsPerWord = 64 / 9
+
+// 元素所在 block 的起始下标，以及 block 内 K 个位的位置
+func (bf *BlockedBloomFilter) locate(item string, positions []uint32) int {
+	hash := bf.hash(item)
+	hi, _ := bits.Mul64(hash, uint64(bf.BlockNum))
+	// block 的选择用掉了 hash 的高位，block 内的位置从重新混合后的值中依次截取 9 bit，K > 7 时继续混合
+	inner := hash
+	for i := range positions {
+		if i%positionsPerWord == 0 {
+			inner = murmur64(inner)
+		}
+		positions[i] = uint32(inner>>(9*(i%positionsPerWord))) % blockBits
+	}
+	return int(hi) * blockWords
+}
+
+func (bf *BlockedBloomFilter) Add(item string) bool {
+	if bf.IsEmpty() {
+		return false
+	}
+	var buf [16]uint32
+	positions := positionBuffer(buf[:], bf.K)
+	base := bf.locate(item, positions)
+	for _, pos := range positions {
+		bf.Words[base+int(pos/64)] |= 1 << (pos % 64)
+	}
+	bf.ElementNum++
+	return true
+}
+
+func (bf *BlockedBloomFilter) BatchAdd(items []string) {
+	for _, item := range items {
+		bf.Add(item)
+	}
+}
+
+func (bf *BlockedBloomFilter) Contains(item string) bool {
+	if bf.IsEmpty() {
+		return false
+	}
+	var buf [16]uint32
+	positions := positionBuffer(buf[:], bf.K)
+	base := bf.locate(item, positions)
+	for _, pos := range positions {
+		if bf.Words[base+int(pos/64)]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// K 不超过 len(buf) 时使用栈上的 buf，避免每次查询分配内存
+func positionBuffer(buf []uint32, k int) []uint32 {
+	if k <= len(buf) {
+		return buf[:k]
+	}
+	return make([]uint32, k)
+}
+
+func (bf *BlockedBloomFilter) IsEmpty() bool {
+	return bf.BlockNum == 0
+}
+
+// 字节数
+func (bf *BlockedBloomFilter) GetBitSize() int {
+	return bf.BlockNum * blockBits / 8
+}
+
+func (bf *BlockedBloomFilter) Count() int {
+	return bf.ElementNum
+}
+
+func (bf *BlockedBloomFilter) FalsePositiveRate() float64 {
+	return bf.Fpr
+}
+
+func (bf *BlockedBloomFilter) Type() string {
+	return FILTER_BLOCKED_BLOOM
+}
+
+// 与 BlockedBloomFilter 字段相同但没有 MarshalBinary 方法，避免 gob 递归调用
+type blockedBloomFilterData BlockedBloomFilter
+
+func (bf *BlockedBloomFilter) MarshalBinary() ([]byte, error) {
+	return gobEncode((*blockedBloomFilterData)(bf))
+}
+
+func decodeBlockedBloomFilter(data []byte) (MembershipFilter, error) {
+	var d blockedBloomFilterData
+	if err := gobDecode(data, &d); err != nil {
+		return nil, err
+	}
+	if d.BlockNum < 0 || len(d.Words) != d.BlockNum*blockWords {
+		return nil, malformed(FILTER_BLOCKED_BLOOM, "%d words for %d blocks", len(d.Words), d.BlockNum)
+	}
+	bf := BlockedBloomFilter(d)
+	return &bf, nil
+}
+
+// 满足误判率的最小 block 数量。先按普通布隆过滤器估计，再逐步增大直到 BlockedFPR 不超过 fpr
+func FindOptimalBlockNum(n int, fpr float64, k int) int {
+	blockNum := max(1, (FindOptimalM(n, fpr, k)+blockBits-1)/blockBits)
+	for BlockedFPR(k, n, blockNum) > fpr {
+		blockNum += max(1, blockNum/32)
+	}
+	return blockNum
+}
+
+// 每个 block 中的元素数量近似服从均值为 n/blockNum 的泊松分布，误判率为各个 block 内普通布隆过滤器误判率的期望
+func BlockedFPR(k, n, blockNum int) float64 {
+	lambda := float64(n) / float64(blockNum)
+	limit := int(lambda + 10*math.Sqrt(lambda) + 10)
+	fpr := 0.0
+	p := math.Exp(-lambda) // P(j = 0)
+	for j := 0; j <= limit; j++ {
+		if j > 0 {
+			p *= lambda / float64(j)
+		}
+		fpr += p * math.Pow(1-math.Pow(1-1.0/blockBits, float64(j*k)), float64(k))
+	}
+	return fpr
+}
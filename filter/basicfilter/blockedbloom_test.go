@@ -0,0 +1,128 @@
+package basicfilter
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockedBloomFilter(t *testing.T) {
+	items := make([]string, 100000)
+	for i := range items {
+		items[i] = fmt.Sprintf("0x%06d", i)
+	}
+	for _, fpr := range []float64{0.01, 0.001} {
+		bf := NewBlockedBloomFilter(len(items), fpr, 7, 1)
+		bf.BatchAdd(items)
+		for _, item := range items {
+			assert.True(t, bf.Contains(item))
+		}
+		fp := 0
+		for i := 0; i < 100000; i++ {
+			if bf.Contains(fmt.Sprintf("0y%06d", i)) {
+				fp++
+			}
+		}
+		assert.True(t, float64(fp)/100000 < fpr*1.3, fp)
+		// 分块带来的空间开销
+		m := FindOptimalM(len(items), fpr, 7)
+		assert.True(t, bf.GetBitSize()*8 >= m)
+		assert.True(t, bf.GetBitSize()*8 < m*3/2)
+	}
+	empty := NewBlockedBloomFilter(0, 0.01, 7, 1)
+	assert.True(t, empty.IsEmpty())
+	assert.False(t, empty.Add("a"))
+	assert.False(t, empty.Contains("a"))
+}
+
+// 相同误判率下 BloomFilter（独立的 k 个哈希、k 次随机访问）与 BlockedBloomFilter（一次哈希、一个 cache line）的查询开销
+func benchmarkFilterContains(b *testing.B, name string, n int, hashGroup *BFHashGroup) {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("0x%08d", i)
+	}
+	queries := make([]string, 1<<16)
+	for i := range queries {
+		if i%2 == 0 {
+			queries[i] = items[rand.Intn(n)]
+		} else {
+			queries[i] = fmt.Sprintf("0y%08d", i)
+		}
+	}
+	f, _ := NewMembershipFilter(name, n, &FilterOptions{Fpr: 0.01, HashFuncNum: 7, HashGroup: hashGroup, Rng: rand.New(rand.NewSource(1))})
+	f.BatchAdd(items)
+	b.ReportMetric(float64(f.GetBitSize()*8)/float64(n), "bits/key")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Contains(queries[i&(len(queries)-1)])
+	}
+}
+
+func BenchmarkFilterContains(b *testing.B) {
+	for _, n := range []int{10000, 1000000} {
+		b.Run(fmt.Sprintf("BF/n=%d", n), func(b *testing.B) {
+			benchmarkFilterContains(b, FILTER_BLOOM, n, nil)
+		})
+		b.Run(fmt.Sprintf("BFHashGroup/n=%d", n), func(b *testing.B) {
+			benchmarkFilterContains(b, FILTER_BLOOM, n, NewBFHashGroup(7))
+		})
+		b.Run(fmt.Sprintf("BBF/n=%d", n), func(b *testing.B) {
+			benchmarkFilterContains(b, FILTER_BLOCKED_BLOOM, n, nil)
+		})
+	}
+}
+
+// 一次查询沿树逐层探测每一层的过滤器，共享 HashGroup 时同一个元素只哈希一次
+func BenchmarkBlockedBloomLevels(b *testing.B) {
+	const levels = 16
+	for _, shared := range []bool{false, true} {
+		b.Run(fmt.Sprintf("shared=%v", shared), func(b *testing.B) {
+			hashGroup := NewBFHashGroup(7)
+			filters := make([]MembershipFilter, levels)
+			for level := range filters {
+				n := 1 << (levels - level)
+				items := make([]string, n)
+				for i := range items {
+					items[i] = fmt.Sprintf("0x%08d", i)
+				}
+				opts := &FilterOptions{Fpr: 0.01, HashFuncNum: 7, Rng: rand.New(rand.NewSource(int64(level)))}
+				if shared {
+					opts.HashGroup = hashGroup
+				}
+				filters[level], _ = NewMembershipFilter(FILTER_BLOCKED_BLOOM, n, opts)
+				filters[level].BatchAdd(items)
+			}
+			queries := make([]string, 1<<10)
+			for i := range queries {
+				queries[i] = fmt.Sprintf("0x%08d", i)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				item := queries[i&(len(queries)-1)]
+				for _, f := range filters {
+					f.Contains(item)
+				}
+			}
+		})
+	}
+}
+
+// 共享 HashGroup 与单独哈希得到相同的结果
+func TestBlockedBloomFilterHashGroup(t *testing.T) {
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = fmt.Sprintf("0x%06d", i)
+	}
+	hashGroup := NewBFHashGroupWithKey(7, "secret")
+	f, _ := NewMembershipFilter(FILTER_BLOCKED_BLOOM, len(items), &FilterOptions{Fpr: 0.01, HashFuncNum: 7, HashGroup: hashGroup})
+	f.BatchAdd(items)
+	plain := NewBlockedBloomFilter(len(items), 0.01, 7, hashGroup.Seeds[0])
+	plain.BatchAdd(items)
+	assert.Equal(t, plain.Words, f.(*BlockedBloomFilter).Words)
+	for i := 0; i < 2000; i++ {
+		item := fmt.Sprintf("0x%06d", i)
+		assert.Equal(t, plain.Contains(item), f.Contains(item))
+	}
+}
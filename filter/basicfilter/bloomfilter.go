@@ -15,6 +15,10 @@ type BloomFilter struct {
 	Fpr      float64 // 误判率
 	HashFunc []*xxhash.Digest
 	Seeds    []uint64
+	// 用于统计已加入的元素数量（重复加入时会重复计数）
+	ElementNum int
+	// 通过 NewBloomFilterWithHashGroup 创建时，Contains 复用 hashGroup 中缓存的哈希值
+	hashGroup *BFHashGroup
 }
 
 // 输出 m
@@ -23,13 +27,17 @@ func (bf *BloomFilter) Size() int {
 }
 
 func NewBloomFilter(n int, fpr float64, k int) *BloomFilter {
+	return NewBloomFilterWithRand(n, fpr, k, rand.New(rand.NewSource(time.Now().UnixNano()))) // 创建一个独立的随机数生成器
+}
+
+// 使用 rng 生成哈希函数的种子
+func NewBloomFilterWithRand(n int, fpr float64, k int, rng *rand.Rand) *BloomFilter {
 	if n == 0 {
 		return NewEmptyBloomFilter()
 	}
 	m := FindOptimalM(n, fpr, k)
 	hashFunc := make([]*xxhash.Digest, k)
 	seeds := make([]uint64, k)
-	rng := rand.New(rand.NewSource(time.Now().UnixNano())) // 创建一个独立的随机数生成器
 	for i := 0; i < k; i++ {
 		seed := rng.Uint64() // 生成随机种子
 		seeds[i] = seed
@@ -53,12 +61,13 @@ func NewBloomFilterWithHashGroup(n int, fpr float64, k int, hashGroup *BFHashGro
 	}
 	m := FindOptimalM(n, fpr, k)
 	return &BloomFilter{
-		BitArray: make([]bool, m),
-		K:        k,
-		M:        m,
-		Fpr:      fpr,
-		HashFunc: hashGroup.HashFunc,
-		Seeds:    hashGroup.Seeds,
+		BitArray:  make([]bool, m),
+		K:         k,
+		M:         m,
+		Fpr:       fpr,
+		HashFunc:  hashGroup.HashFunc,
+		Seeds:     hashGroup.Seeds,
+		hashGroup: hashGroup,
 	}
 }
 
@@ -77,7 +86,7 @@ func (bf *BloomFilter) IsEmpty() bool {
 	return bf.M == 0
 }
 
-func (bf *BloomFilter) Add(item string) {
+func (bf *BloomFilter) Add(item string) bool {
 	for i, hashFunc := range bf.HashFunc {
 		// !!!
 		// hashFunc.ResetWithSeed(uint64(i))
@@ -86,6 +95,8 @@ func (bf *BloomFilter) Add(item string) {
 		index := hashFunc.Sum64() % uint64(bf.M)
 		bf.BitArray[index] = true
 	}
+	bf.ElementNum++
+	return true
 }
 
 func (bf *BloomFilter) BatchAdd(items []string) {
@@ -131,6 +142,88 @@ func (bf *BloomFilter) GetWithHashGroup(item string, hashGroup *BFHashGroup) boo
 	return true
 }
 
+func (bf *BloomFilter) Contains(item string) bool {
+	if bf.hashGroup != nil {
+		return bf.GetWithHashGroup(item, bf.hashGroup)
+	}
+	return bf.Get(item)
+}
+
+func (bf *BloomFilter) Count() int {
+	return bf.ElementNum
+}
+
+// 构建时设定的误判率
+func (bf *BloomFilter) FalsePositiveRate() float64 {
+	return bf.Fpr
+}
+
+func (bf *BloomFilter) Type() string {
+	return FILTER_BLOOM
+}
+
+// 序列化的内容，哈希函数由 Seeds 重新生成
+type bloomFilterData struct {
+	K          int
+	M          int
+	Fpr        float64
+	Seeds      []uint64
+	ElementNum int
+	Bits       []byte
+}
+
+func (bf *BloomFilter) MarshalBinary() ([]byte, error) {
+	bits := make([]byte, (bf.M+7)/8)
+	for i, bit := range bf.BitArray {
+		if bit {
+			bits[i/8] |= 1 << (i % 8)
+		}
+	}
+	return gobEncode(&bloomFilterData{K: bf.K, M: bf.M, Fpr: bf.Fpr, Seeds: bf.Seeds, ElementNum: bf.ElementNum, Bits: bits})
+}
+
+func decodeBloomFilter(data []byte) (MembershipFilter, error) {
+	var d bloomFilterData
+	if err := gobDecode(data, &d); err != nil {
+		return nil, err
+	}
+	if d.M == 0 {
+		return NewEmptyBloomFilter(), nil
+	}
+	if d.M < 0 || len(d.Bits) != (d.M+7)/8 {
+		return nil, malformed(FILTER_BLOOM, "%d bytes for %d bits", len(d.Bits), d.M)
+	}
+	if len(d.Seeds) == 0 {
+		return nil, malformed(FILTER_BLOOM, "no hash seeds")
+	}
+	bf := &BloomFilter{
+		BitArray:   make([]bool, d.M),
+		K:          d.K,
+		M:          d.M,
+		Fpr:        d.Fpr,
+		HashFunc:   make([]*xxhash.Digest, len(d.Seeds)),
+		Seeds:      d.Seeds,
+		ElementNum: d.ElementNum,
+	}
+	for i, seed := range d.Seeds {
+		bf.HashFunc[i] = xxhash.NewWithSeed(seed)
+	}
+	for i := range bf.BitArray {
+		bf.BitArray[i] = d.Bits[i/8]&(1<<(i%8)) != 0
+	}
+	return bf, nil
+}
+
+func newBloomMembershipFilter(n int, opts *FilterOptions) MembershipFilter {
+	if opts.HashGroup != nil {
+		return NewBloomFilterWithHashGroup(n, opts.Fpr, opts.HashFuncNum, opts.HashGroup)
+	}
+	if opts.Rng != nil {
+		return NewBloomFilterWithRand(n, opts.Fpr, opts.HashFuncNum, opts.Rng)
+	}
+	return NewBloomFilter(n, opts.Fpr, opts.HashFuncNum)
+}
+
 // 获取布隆过滤器的大小（实际返回的字节）
 func (bf *BloomFilter) GetBitSize() int {
 	return bf.M / 8
@@ -148,3 +241,19 @@ func calculateFPR(k, n, m int) float64 {
 	exponent := float64(-k*n) / float64(m)
 	return math.Pow(1-math.Exp(exponent), float64(k))
 }
+
+// FindOptimalM 的解析解，用于估计大量参数组合时避免逐个尝试 m
+func EstimateM(n int, fpr float64, k int) int {
+	if n == 0 {
+		return 0
+	}
+	m := int(math.Ceil(-float64(k*n) / math.Log(1-math.Pow(fpr, 1/float64(k)))))
+	// 浮点误差可能导致相差 1
+	for m > n && calculateFPR(k, n, m-1) <= fpr {
+		m--
+	}
+	for calculateFPR(k, n, m) > fpr {
+		m++
+	}
+	return max(m, n)
+}
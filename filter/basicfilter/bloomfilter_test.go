@@ -3,6 +3,7 @@ package basicfilter
 import (
 	"fmt"
 	"log"
+	"math/rand"
 	"testing"
 
 	"github.com/cespare/xxhash/v2"
@@ -61,3 +62,31 @@ func TestOptimalM(t *testing.T) {
 	m := FindOptimalM(n, fpr, 7)
 	fmt.Println("Optimal m is", m)
 }
+
+func TestEstimateM(t *testing.T) {
+	for _, n := range []int{1, 10, 123, 1000} {
+		for _, fpr := range []float64{0.1, 0.01, 0.001} {
+			assert.Equal(t, FindOptimalM(n, fpr, 7), EstimateM(n, fpr, 7))
+		}
+	}
+}
+
+func TestBloomFilterWithRand(t *testing.T) {
+	bf1 := NewBloomFilterWithRand(100, 0.01, 7, rand.New(rand.NewSource(1)))
+	bf2 := NewBloomFilterWithRand(100, 0.01, 7, rand.New(rand.NewSource(1)))
+	assert.Equal(t, bf1.Seeds, bf2.Seeds)
+}
+
+func TestKeyedSeed(t *testing.T) {
+	assert.Equal(t, KeyedSeed("secret", "bf", 0), KeyedSeed("secret", "bf", 0))
+	assert.NotEqual(t, KeyedSeed("secret", "bf", 0), KeyedSeed("secret", "bf", 1))
+	assert.NotEqual(t, KeyedSeed("secret", "bf", 0), KeyedSeed("secret", "anchor", 0))
+	assert.NotEqual(t, KeyedSeed("secret", "bf", 0), KeyedSeed("other", "bf", 0))
+
+	assert.Equal(t, NewBFHashGroup(3).Seeds, NewBFHashGroupWithKey(3, "").Seeds)
+	hg := NewBFHashGroupWithKey(3, "secret")
+	assert.NotEqual(t, NewBFHashGroup(3).Seeds, hg.Seeds)
+	bf := NewBloomFilterWithHashGroup(100, 0.01, 3, hg)
+	bf.Add("0xabc")
+	assert.True(t, bf.GetWithHashGroup("0xabc", hg))
+}
@@ -1,9 +1,10 @@
 package basicfilter
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"encoding/binary"
 	"encoding/hex"
-	"log"
 	"math"
 	"math/rand"
 )
@@ -24,10 +25,18 @@ type CuckooFilter struct {
 	fingerprintSize int
 	fingerprintNum  int
 	maxKickAttempts int
+	rng             *rand.Rand // 踢出时使用的随机数来源，为 nil 时使用全局的 rand
+	count           int        // 成功加入的元素数量（重复加入时会重复计数）
+	key             []byte     // 不为 nil 时使用 HMAC-MD5 计算下标与指纹
 }
 
 // NewCuckooFilter 创建一个新的布谷鸟哈希表
 func NewCuckooFilter(bucketPow int, fingerprintSize int, fingerprintNum int, maxKickAttempts int) *CuckooFilter {
+	return NewCuckooFilterWithRand(bucketPow, fingerprintSize, fingerprintNum, maxKickAttempts, nil)
+}
+
+// 使用 rng 选择插入与踢出的位置
+func NewCuckooFilterWithRand(bucketPow int, fingerprintSize int, fingerprintNum int, maxKickAttempts int, rng *rand.Rand) *CuckooFilter {
 	numBuckets := 1 << bucketPow
 	buckets := make([]*Bucket, numBuckets)
 	for i := range buckets {
@@ -46,16 +55,32 @@ func NewCuckooFilter(bucketPow int, fingerprintSize int, fingerprintNum int, max
 		fingerprintSize: fingerprintSize,
 		fingerprintNum:  fingerprintNum,
 		maxKickAttempts: maxKickAttempts,
+		rng:             rng,
 	}
 }
 
+func (cf *CuckooFilter) randIntn(n int) int {
+	if cf.rng == nil {
+		return rand.Intn(n)
+	}
+	return cf.rng.Intn(n)
+}
+
 func NewCuckooFilterWithEstimation(elementNum int, fingerprintSize int, fingerprintNum int, maxKickAttempts int) *CuckooFilter {
 	bucketPow := EstimateBucketPow(elementNum, fingerprintNum, 0)
 	return NewCuckooFilter(bucketPow, fingerprintSize, fingerprintNum, maxKickAttempts)
 }
 
+// 使用 seed 作为 HMAC 的密钥，seed 由 HashKey 派生时不知道密钥无法构造冲突的元素
+func (cf *CuckooFilter) setKey(seed uint64) {
+	cf.key = binary.BigEndian.AppendUint64(nil, seed)
+}
+
 func (cf *CuckooFilter) hash(item string) string {
 	h := md5.New()
+	if cf.key != nil {
+		h = hmac.New(md5.New, cf.key)
+	}
 	h.Write([]byte(item))
 	return hex.EncodeToString(h.Sum(nil))
 }
@@ -111,16 +136,17 @@ func (cf *CuckooFilter) hasEmpty(bucket_index int) int {
 }
 
 func (cf *CuckooFilter) Add(item string) bool {
+	if cf.IsEmpty() {
+		return false
+	}
 	fingerprint := cf.fingerprint(item)
 
 	bucketIndex := cf.GetIndex(item)
 	altBucketIndex := cf.getAltIndex(bucketIndex, fingerprint)
 
-	if cf.contains(bucketIndex, fingerprint) {
-		return true
-	}
-
-	if cf.contains(altBucketIndex, fingerprint) {
+	// 指纹已存在时不重复存放，与 BloomFilter 一样仍然计数
+	if cf.contains(bucketIndex, fingerprint) || cf.contains(altBucketIndex, fingerprint) {
+		cf.count++
 		return true
 	}
 
@@ -129,50 +155,58 @@ func (cf *CuckooFilter) Add(item string) bool {
 
 	// 若两个 bucket 都有空位，则随机选择一个 bucket
 	if emptyFlag != -1 && altEmptyFlag != -1 {
-		if rand.Intn(2) == 1 {
+		if cf.randIntn(2) == 1 {
 			cf.buckets[bucketIndex].Fingerprints[emptyFlag] = fingerprint
 		} else {
 			cf.buckets[altBucketIndex].Fingerprints[altEmptyFlag] = fingerprint
 		}
+		cf.count++
 		return true
 	}
 
 	if emptyFlag != -1 {
 		cf.buckets[bucketIndex].Fingerprints[emptyFlag] = fingerprint
+		cf.count++
 		return true
 	}
 
 	if altEmptyFlag != -1 {
 		cf.buckets[altBucketIndex].Fingerprints[altEmptyFlag] = fingerprint
+		cf.count++
 		return true
 	}
 
 	selectBucketIndex := bucketIndex
-	if rand.Intn(2) == 1 {
+	if cf.randIntn(2) == 1 {
 		selectBucketIndex = altBucketIndex
 	}
 
-	tmpFingerprint := cf.swap(selectBucketIndex, rand.Intn(cf.fingerprintNum), fingerprint)
+	tmpFingerprint := cf.swap(selectBucketIndex, cf.randIntn(cf.fingerprintNum), fingerprint)
 	kickCount := 1
 	altBucketIndex = cf.getAltIndex(selectBucketIndex, tmpFingerprint)
 
-	emptyFlag = cf.hasEmpty(selectBucketIndex)
+	// 被踢出的指纹需要放到它的另一个 bucket 中
+	emptyFlag = cf.hasEmpty(altBucketIndex)
 	for emptyFlag == -1 {
-		tmpFingerprint = cf.swap(altBucketIndex, rand.Intn(cf.fingerprintNum), tmpFingerprint)
+		tmpFingerprint = cf.swap(altBucketIndex, cf.randIntn(cf.fingerprintNum), tmpFingerprint)
 		altBucketIndex = cf.getAltIndex(altBucketIndex, tmpFingerprint)
 		kickCount++
 		if kickCount > cf.maxKickAttempts {
-			panic("max kick attempts reached")
+			// 最后被踢出的指纹丢失，调用方需要扩容后重建
+			return false
 		}
-		emptyFlag = cf.hasEmpty(selectBucketIndex)
+		emptyFlag = cf.hasEmpty(altBucketIndex)
 	}
 
 	cf.buckets[altBucketIndex].Fingerprints[emptyFlag] = tmpFingerprint
-	log.Printf("===== add finish =====")
+	cf.count++
 	return true
 }
 
 func (cf *CuckooFilter) Get(item string) bool {
+	if cf.IsEmpty() {
+		return false
+	}
 	fingerprint := cf.fingerprint(item)
 	index := cf.GetIndex(item)
 	altIndex := cf.getAltIndex(index, fingerprint)
@@ -219,3 +253,138 @@ func (cf *CuckooFilter) FingerprintSize() int {
 func (cf *CuckooFilter) Buckets() []*Bucket {
 	return cf.buckets
 }
+
+func (cf *CuckooFilter) IsEmpty() bool {
+	return cf.numBuckets == 0
+}
+
+// 一次性加入所有元素，空间不足时 bucket 数量翻倍后只用 items 重建
+func (cf *CuckooFilter) BatchAdd(items []string) {
+	if cf.IsEmpty() {
+		return
+	}
+	for {
+		ok := true
+		for _, item := range items {
+			if !cf.Add(item) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return
+		}
+		key := cf.key
+		*cf = *NewCuckooFilterWithRand(cf.bucketPow+1, cf.fingerprintSize, cf.fingerprintNum, cf.maxKickAttempts, cf.rng)
+		cf.key = key
+	}
+}
+
+func (cf *CuckooFilter) Contains(item string) bool {
+	return cf.Get(item)
+}
+
+func (cf *CuckooFilter) Count() int {
+	return cf.count
+}
+
+// 指纹为 fingerprintSize 个十六进制字符，查询时检查两个 bucket 中已占用的 slot
+func (cf *CuckooFilter) FalsePositiveRate() float64 {
+	if cf.IsEmpty() {
+		return 0
+	}
+	occupied := 2 * float64(cf.count) / float64(cf.numBuckets)
+	return 1 - math.Pow(1-math.Pow(16, -float64(cf.fingerprintSize)), occupied)
+}
+
+// 字节数
+func (cf *CuckooFilter) GetBitSize() int {
+	return cf.numBuckets * cf.fingerprintNum * cf.fingerprintSize
+}
+
+func (cf *CuckooFilter) Type() string {
+	return FILTER_CUCKOO
+}
+
+type cuckooFilterData struct {
+	BucketPow       int
+	FingerprintSize int
+	FingerprintNum  int
+	MaxKickAttempts int
+	Count           int
+	Key             []byte
+	Buckets         [][][]byte
+}
+
+func (cf *CuckooFilter) MarshalBinary() ([]byte, error) {
+	d := &cuckooFilterData{
+		BucketPow:       cf.bucketPow,
+		FingerprintSize: cf.fingerprintSize,
+		FingerprintNum:  cf.fingerprintNum,
+		MaxKickAttempts: cf.maxKickAttempts,
+		Count:           cf.count,
+		Key:             cf.key,
+		Buckets:         make([][][]byte, len(cf.buckets)),
+	}
+	for i, b := range cf.buckets {
+		d.Buckets[i] = b.Fingerprints
+	}
+	return gobEncode(d)
+}
+
+func decodeCuckooFilter(data []byte) (MembershipFilter, error) {
+	var d cuckooFilterData
+	if err := gobDecode(data, &d); err != nil {
+		return nil, err
+	}
+	if len(d.Buckets) == 0 {
+		return &CuckooFilter{}, nil
+	}
+	if d.BucketPow < 0 || d.BucketPow > 30 || len(d.Buckets) != 1<<d.BucketPow {
+		return nil, malformed(FILTER_CUCKOO, "%d buckets for bucket pow %d", len(d.Buckets), d.BucketPow)
+	}
+	if d.FingerprintSize <= 0 || d.FingerprintNum <= 0 {
+		return nil, malformed(FILTER_CUCKOO, "fingerprint size %d, slot num %d", d.FingerprintSize, d.FingerprintNum)
+	}
+	for i, fps := range d.Buckets {
+		if len(fps) != d.FingerprintNum {
+			return nil, malformed(FILTER_CUCKOO, "bucket %d has %d slots, want %d", i, len(fps), d.FingerprintNum)
+		}
+		for _, fp := range fps {
+			if len(fp) != d.FingerprintSize {
+				return nil, malformed(FILTER_CUCKOO, "bucket %d has a %d byte fingerprint, want %d", i, len(fp), d.FingerprintSize)
+			}
+		}
+	}
+	cf := NewCuckooFilter(d.BucketPow, d.FingerprintSize, d.FingerprintNum, d.MaxKickAttempts)
+	cf.count = d.Count
+	cf.key = d.Key
+	for i, fps := range d.Buckets {
+		cf.buckets[i].Fingerprints = fps
+	}
+	return cf, nil
+}
+
+const (
+	cuckooSlotNum         = 4
+	cuckooMaxKickAttempts = 500
+	cuckooMaxLoad         = 0.9
+)
+
+// 指纹长度按误判率选择：2 * slotNum / 16^f <= fpr
+func newCuckooMembershipFilter(n int, opts *FilterOptions) MembershipFilter {
+	if n == 0 {
+		return &CuckooFilter{}
+	}
+	fingerprintSize := max(1, int(math.Ceil(math.Log(2*cuckooSlotNum/opts.Fpr)/math.Log(16))))
+	bucketPow := EstimateBucketPow(n, cuckooSlotNum, 0)
+	if float64(n) > cuckooMaxLoad*float64(int(1)<<bucketPow*cuckooSlotNum) {
+		bucketPow++
+	}
+	cf := NewCuckooFilterWithRand(bucketPow, fingerprintSize, cuckooSlotNum, cuckooMaxKickAttempts, opts.Rng)
+	// 与 BlockedBloomFilter、BinaryFuse8 一样使用 HashGroup 的第一个种子，HashKey 不为空时该种子由密钥派生
+	if opts.HashGroup != nil && len(opts.HashGroup.Seeds) > 0 {
+		cf.setKey(opts.HashGroup.Seeds[0])
+	}
+	return cf
+}
@@ -123,3 +123,34 @@ func generateConflictKeys(cf *CuckooFilter, count int, k int) []string {
 	}
 	return keys
 }
+
+// 使用 HashGroup 创建时下标与指纹由 HashKey 决定，编码与扩容后密钥保持不变
+func TestCuckooFilterKeyed(t *testing.T) {
+	items := make([]string, 100)
+	for i := range items {
+		items[i] = fmt.Sprintf("0x%04d", i)
+	}
+	newKeyed := func(key string) *CuckooFilter {
+		f, _ := NewMembershipFilter(FILTER_CUCKOO, len(items), &FilterOptions{Fpr: 0.01, HashGroup: NewBFHashGroupWithKey(3, key)})
+		return f.(*CuckooFilter)
+	}
+	cf, other := newKeyed("secret"), newKeyed("other")
+	diff := 0
+	for _, item := range items {
+		if cf.GetIndex(item) != other.GetIndex(item) || string(cf.fingerprint(item)) != string(other.fingerprint(item)) {
+			diff++
+		}
+	}
+	assert.True(t, diff > len(items)/2)
+	assert.Equal(t, newKeyed("secret").GetIndex(items[0]), cf.GetIndex(items[0]))
+
+	cf.BatchAdd(append(items, items...))
+	data, err := MarshalFilter(cf)
+	assert.Nil(t, err)
+	decoded, err := UnmarshalFilter(data)
+	assert.Nil(t, err)
+	for _, item := range items {
+		assert.True(t, cf.Contains(item))
+		assert.True(t, decoded.Contains(item))
+	}
+}
@@ -1,6 +1,12 @@
 package basicfilter
 
-import "github.com/cespare/xxhash/v2"
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
 
 type BFHashGroup struct {
 	HashFunc  []*xxhash.Digest // 保存哈希函数
@@ -22,6 +28,35 @@ func NewBFHashGroup(num int) *BFHashGroup {
 	}
 }
 
+// 使用由密钥派生的种子，不知道密钥时无法直接计算哈希值（xxhash 不是带密钥的伪随机函数，见 CSCTreeConfig.HashKey），key 为空时与 NewBFHashGroup 相同
+func NewBFHashGroupWithKey(num int, key string) *BFHashGroup {
+	if key == "" {
+		return NewBFHashGroup(num)
+	}
+	hashFunc := make([]*xxhash.Digest, num)
+	seeds := make([]uint64, num)
+	for i := 0; i < num; i++ {
+		seeds[i] = KeyedSeed(key, "bf", i)
+		hashFunc[i] = xxhash.NewWithSeed(seeds[i])
+	}
+	return &BFHashGroup{
+		HashFunc: hashFunc,
+		Seeds:    seeds,
+	}
+}
+
+// 由密钥派生哈希种子：SHA-256(key || 0 || label || 0 || index) 的前 8 个字节，
+// label 区分不同用途（BF、Anchor、Offset、Fingerprint）的种子
+func KeyedSeed(key string, label string, index int) uint64 {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(label))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(index)))
+	return binary.BigEndian.Uint64(h.Sum(nil)[:8])
+}
+
 func (hashGroup *BFHashGroup) ResetWithSeed() {
 	for i, hashFunc := range hashGroup.HashFunc {
 		hashFunc.ResetWithSeed(hashGroup.Seeds[i])
@@ -0,0 +1,139 @@
+package basicfilter
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// 树节点上用于判断元素是否属于兄弟节点交集的过滤器
+type MembershipFilter interface {
+	// 加入一个元素，失败（例如静态过滤器或空间不足）时返回 false
+	Add(item string) bool
+	// 一次性加入所有元素，静态过滤器只能通过该方法构建
+	BatchAdd(items []string)
+	Contains(item string) bool
+	IsEmpty() bool
+	// 占用的字节数
+	GetBitSize() int
+	// 加入的元素数量
+	Count() int
+	FalsePositiveRate() float64
+	// 在 RegisterFilter 中注册的类型名
+	Type() string
+	MarshalBinary() ([]byte, error)
+}
+
+// 创建过滤器时可用的参数，各实现只使用自己需要的部分
+type FilterOptions struct {
+	Fpr         float64
+	HashFuncNum int
+	HashGroup   *BFHashGroup // 为 nil 时使用随机种子，CuckooFilter 不使用密钥
+	Rng         *rand.Rand
+}
+
+// 根据元素数量 n 创建一个空的过滤器
+type FilterFactory func(n int, opts *FilterOptions) MembershipFilter
+
+// 从 MarshalBinary 的结果恢复过滤器
+type FilterDecoder func(data []byte) (MembershipFilter, error)
+
+type filterEntry struct {
+	factory FilterFactory
+	decoder FilterDecoder
+}
+
+var filterRegistry = make(map[string]*filterEntry)
+
+const (
+	FILTER_BLOOM         = "BF"
+	FILTER_CUCKOO        = "CF"
+	FILTER_BINARY_FUSE   = "BFUSE"
+	FILTER_BLOCKED_BLOOM = "BBF"
+)
+
+func init() {
+	RegisterFilter(FILTER_BLOOM, newBloomMembershipFilter, decodeBloomFilter)
+	RegisterFilter(FILTER_CUCKOO, newCuckooMembershipFilter, decodeCuckooFilter)
+	RegisterFilter(FILTER_BINARY_FUSE, newBinaryFuseMembershipFilter, decodeBinaryFuse8)
+	RegisterFilter(FILTER_BLOCKED_BLOOM, newBlockedBloomMembershipFilter, decodeBlockedBloomFilter)
+}
+
+// 注册一种过滤器，name 用于配置以及序列化
+func RegisterFilter(name string, factory FilterFactory, decoder FilterDecoder) {
+	filterRegistry[name] = &filterEntry{factory: factory, decoder: decoder}
+}
+
+// 已注册的过滤器类型（有序）
+func FilterTypes() []string {
+	names := make([]string, 0, len(filterRegistry))
+	for name := range filterRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func IsFilterType(name string) bool {
+	_, ok := filterRegistry[name]
+	return ok
+}
+
+func NewMembershipFilter(name string, n int, opts *FilterOptions) (MembershipFilter, error) {
+	entry, ok := filterRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter type %q", name)
+	}
+	return entry.factory(n, opts), nil
+}
+
+// 序列化时带上类型名，UnmarshalFilter 根据类型名选择解码方法
+type filterEnvelope struct {
+	Type string
+	Data []byte
+}
+
+func MarshalFilter(f MembershipFilter) ([]byte, error) {
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return gobEncode(&filterEnvelope{Type: f.Type(), Data: data})
+}
+
+func UnmarshalFilter(data []byte) (MembershipFilter, error) {
+	var env filterEnvelope
+	if err := gobDecode(data, &env); err != nil {
+		return nil, err
+	}
+	entry, ok := filterRegistry[env.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter type %q", env.Type)
+	}
+	return entry.decoder(env.Data)
+}
+
+// 解码得到的字段与数据长度不一致，继续使用会越界
+var ErrMalformedFilter = errors.New("malformed filter encoding")
+
+func malformed(name string, format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", ErrMalformedFilter, name, fmt.Sprintf(format, args...))
+}
+
+func gobEncode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode filter: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDecode(data []byte, v any) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode filter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package basicfilter
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembershipFilterRoundTrip(t *testing.T) {
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = fmt.Sprintf("0x%06d", i)
+	}
+	for _, name := range FilterTypes() {
+		f, err := NewMembershipFilter(name, len(items), &FilterOptions{Fpr: 0.01, HashFuncNum: 7, Rng: rand.New(rand.NewSource(1))})
+		assert.Nil(t, err)
+		f.BatchAdd(items)
+		assert.Equal(t, name, f.Type())
+		assert.Equal(t, len(items), f.Count())
+		assert.True(t, f.GetBitSize() > 0)
+		for _, item := range items {
+			assert.True(t, f.Contains(item), name)
+		}
+		data, err := MarshalFilter(f)
+		assert.Nil(t, err)
+		decoded, err := UnmarshalFilter(data)
+		assert.Nil(t, err)
+		assert.Equal(t, name, decoded.Type())
+		assert.Equal(t, f.Count(), decoded.Count())
+		fp := 0
+		for i := 0; i < 10000; i++ {
+			item := fmt.Sprintf("0y%06d", i)
+			assert.Equal(t, f.Contains(item), decoded.Contains(item))
+			if f.Contains(item) {
+				fp++
+			}
+		}
+		assert.True(t, float64(fp)/10000 < 0.03, name)
+		for _, item := range items {
+			assert.True(t, decoded.Contains(item), name)
+		}
+
+		empty, _ := NewMembershipFilter(name, 0, &FilterOptions{Fpr: 0.01, HashFuncNum: 7})
+		assert.True(t, empty.IsEmpty())
+		assert.False(t, empty.Contains(items[0]))
+	}
+	_, err := NewMembershipFilter("XF", 10, &FilterOptions{})
+	assert.NotNil(t, err)
+}
+
+// 空间不足时 BatchAdd 扩容重建，不会丢失元素
+func TestCuckooFilterBatchAddGrows(t *testing.T) {
+	cf := NewCuckooFilter(2, 4, 4, 10)
+	items := make([]string, 200)
+	for i := range items {
+		items[i] = fmt.Sprintf("item%d", i)
+	}
+	cf.BatchAdd(items)
+	assert.Equal(t, len(items), cf.Count())
+	assert.True(t, cf.BucketSize() >= len(items)/4)
+	for _, item := range items {
+		assert.True(t, cf.Contains(item))
+	}
+}
+
+// 截断或字段不一致的编码返回错误而不是 panic
+func TestUnmarshalMalformedFilter(t *testing.T) {
+	items := []string{"0x01", "0x02", "0x03"}
+	for _, name := range FilterTypes() {
+		f, _ := NewMembershipFilter(name, len(items), &FilterOptions{Fpr: 0.01, HashFuncNum: 7, Rng: rand.New(rand.NewSource(1))})
+		f.BatchAdd(items)
+		data, _ := MarshalFilter(f)
+		for _, n := range []int{0, len(data) / 2, len(data) - 1} {
+			assert.NotPanics(t, func() {
+				_, err := UnmarshalFilter(data[:n])
+				assert.NotNil(t, err, name)
+			}, name)
+		}
+	}
+
+	tampered := func(name string, v any) []byte {
+		data, err := gobEncode(v)
+		assert.Nil(t, err)
+		data, err = gobEncode(&filterEnvelope{Type: name, Data: data})
+		assert.Nil(t, err)
+		return data
+	}
+	cases := map[string][]byte{
+		"short bits":        tampered(FILTER_BLOOM, &bloomFilterData{K: 1, M: 64, Seeds: []uint64{1}, Bits: make([]byte, 7)}),
+		"missing buckets":   tampered(FILTER_CUCKOO, &cuckooFilterData{BucketPow: 3, FingerprintSize: 2, FingerprintNum: 4, Buckets: make([][][]byte, 4)}),
+		"short bucket":      tampered(FILTER_CUCKOO, &cuckooFilterData{BucketPow: 0, FingerprintSize: 2, FingerprintNum: 4, Buckets: [][][]byte{{{0, 0}, {0, 0}}}}),
+		"short fingerprint": tampered(FILTER_CUCKOO, &cuckooFilterData{BucketPow: 0, FingerprintSize: 2, FingerprintNum: 1, Buckets: [][][]byte{{{0}}}}),
+		"short words":       tampered(FILTER_BLOCKED_BLOOM, &blockedBloomFilterData{BlockNum: 2, K: 7, Words: make([]uint64, blockWords)}),
+		"short fuse":        tampered(FILTER_BINARY_FUSE, &binaryFuse8Data{SegmentLength: 4, SegmentLengthMask: 3, SegmentCount: 2, SegmentCountLength: 8, Fingerprints: make([]uint8, 8)}),
+	}
+	for name, data := range cases {
+		assert.NotPanics(t, func() {
+			_, err := UnmarshalFilter(data)
+			assert.ErrorIs(t, err, ErrMalformedFilter, name)
+		}, name)
+	}
+}
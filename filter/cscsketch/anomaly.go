@@ -0,0 +1,57 @@
+package cscsketch
+
+import "math"
+
+// 随机地址下每个 bucket 作为首选位置的负载近似服从泊松分布，超过平均值 AnomalyZScore 个标准差即视为异常
+const AnomalyZScore = 6
+
+// 以某个 bucket 为首选位置的元素数量明显高于平均值，通常说明有人构造了冲突的地址
+type BucketAnomaly struct {
+	CSC      int     `json:"csc"` // CSCR 中 CSC 的下标
+	Bucket   int     `json:"bucket"`
+	Load     int     `json:"load"`     // 以该 bucket 为首选位置的元素数量
+	Expected float64 `json:"expected"` // 平均每个 bucket 的负载
+}
+
+// 统计 (items[i], fileIds[i]) 在每个 bucket 上的首选位置负载
+func (csc *CSC) BucketLoads(items []string, fileIds []string) []int {
+	loads := make([]int, csc.NumBuckets)
+	if csc.IsEmpty() {
+		return loads
+	}
+	for i, item := range items {
+		loads[csc.GetIndex(item, fileIds[i])]++
+	}
+	return loads
+}
+
+// 返回负载超过 max(2*SlotNum, 平均值 + AnomalyZScore*sqrt(平均值)) 的 bucket
+func (csc *CSC) LoadAnomalies(items []string, fileIds []string) []*BucketAnomaly {
+	res := make([]*BucketAnomaly, 0)
+	if csc.IsEmpty() || len(items) == 0 {
+		return res
+	}
+	mean := float64(len(items)) / float64(csc.NumBuckets)
+	limit := math.Max(float64(2*csc.SlotNum), mean+AnomalyZScore*math.Sqrt(mean))
+	for bucket, load := range csc.BucketLoads(items, fileIds) {
+		if float64(load) > limit {
+			res = append(res, &BucketAnomaly{Bucket: bucket, Load: load, Expected: mean})
+		}
+	}
+	return res
+}
+
+// 检查 CSCR 中的每一个 CSC，HashMap 类型的 CSCR 不存在 bucket，返回空
+func (cscr *CSCR) LoadAnomalies(items []string, fileIds []string) []*BucketAnomaly {
+	res := make([]*BucketAnomaly, 0)
+	if cscr.CType != SKETCH {
+		return res
+	}
+	for i, csc := range cscr.CSCs {
+		for _, a := range csc.LoadAnomalies(items, fileIds) {
+			a.CSC = i
+			res = append(res, a)
+		}
+	}
+	return res
+}
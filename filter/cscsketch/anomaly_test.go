@@ -0,0 +1,53 @@
+package cscsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 已知种子时构造首选位置都落在 bucket 上的地址
+func grindKeys(csc *CSC, bucket int, fileId string, count int) []string {
+	keys := make([]string, 0, count)
+	for i := 0; len(keys) < count; i++ {
+		key := fmt.Sprintf("0xattack%d", i)
+		if csc.GetIndex(key, fileId) == bucket {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestLoadAnomalies(t *testing.T) {
+	public := NewCSCCacheListWithKey(1, rand.New(rand.NewSource(1)), "")
+	keyed := NewCSCCacheListWithKey(1, rand.New(rand.NewSource(1)), "secret")
+	assert.NotEqual(t, public.CSCCacheList[0].SeedAnchor, keyed.CSCCacheList[0].SeedAnchor)
+	assert.NotEqual(t, uint64(0), keyed.CSCCacheList[0].SeedFingerprint)
+
+	victim := NewCSCWithCache(8, 8, 4, 30, 4, public.CSCCacheList[0])
+	items := generateRandomStrings(20, 500, rand.New(rand.NewSource(2)))
+	fileIds := make([]string, 0)
+	for i := range items {
+		fileIds = append(fileIds, fmt.Sprint(i%4))
+	}
+	// 随机的地址不会触发
+	assert.Empty(t, victim.LoadAnomalies(items, fileIds))
+
+	attack := grindKeys(victim, 7, "0", 40)
+	for range attack {
+		fileIds = append(fileIds, "0")
+	}
+	items = append(items, attack...)
+	anomalies := victim.LoadAnomalies(items, fileIds)
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, 7, anomalies[0].Bucket)
+	assert.True(t, anomalies[0].Load >= 40)
+
+	// 不知道密钥时构造的地址会分散到不同的 bucket
+	other := NewCSCWithCache(8, 8, 4, 30, 4, keyed.CSCCacheList[0])
+	assert.Empty(t, other.LoadAnomalies(items, fileIds))
+	assert.True(t, other.Add(attack[0], "0"))
+	assert.Contains(t, other.Get(attack[0]), "0")
+}
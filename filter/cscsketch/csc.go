@@ -19,16 +19,32 @@ type CSC struct {
 	SlotNum                int
 	MaxKickAttempts        int
 	// CSC 额外的配置信息
-	SeedAnchor   uint64
-	SeedOffset   uint64
-	PartitionNum int
-	Partitions   *GlobalPartition
+	SeedAnchor      uint64
+	SeedOffset      uint64
+	SeedFingerprint uint64 // 计算指纹的种子，不使用密钥时为 0
+	PartitionNum    int
+	Partitions      *GlobalPartition
 	// 用于统计利用率
 	Utilization_count int
+	// 引用计数模式：每次 Add 都单独占用一个 slot，分区记录每个 fileId 的引用次数，此时才支持 Remove
+	RefCounted bool
+	// 踢出失败时无处存放的指纹，查询时同样检查，最多 StashSize 个
+	Stash []StashEntry
+	// 生成种子以及踢出时使用的随机数来源，为 nil 时使用全局的 rand
+	rng *rand.Rand
+	// 构建期间保留加入的 (item, fileId)，用于扩容时重新插入，Seal 后释放
+	growable bool
+	entries  []cscEntry
+	doubles  int // 扩容的次数
 }
 
 // 所有 csc 的变种都基于这个构造方法，并不会直接调用这个方法，而是调用 NewCSCWithEstimation
 func NewCSC(bucketPow int, fingerprintSize int, slotNum int, maxKickAttempts int, partitionNum int) *CSC {
+	return NewCSCWithRand(bucketPow, fingerprintSize, slotNum, maxKickAttempts, partitionNum, nil)
+}
+
+// 使用 rng 生成种子并决定踢出的位置，相同的 rng 与插入顺序得到完全相同的 CSC
+func NewCSCWithRand(bucketPow int, fingerprintSize int, slotNum int, maxKickAttempts int, partitionNum int, rng *rand.Rand) *CSC {
 	numBuckets := 1 << bucketPow
 	buckets := make([]*basicfilter.Bucket, numBuckets)
 	fingerprintByteArrSize := int(math.Ceil(float64(fingerprintSize) / float64(8)))
@@ -40,8 +56,8 @@ func NewCSC(bucketPow int, fingerprintSize int, slotNum int, maxKickAttempts int
 			buckets[i].Fingerprints[j] = make([]byte, fingerprintByteArrSize)
 		}
 	}
-	seedAnchor := rand.Uint64()
-	seedOffset := rand.Uint64()
+	seedAnchor := randUint64(rng)
+	seedOffset := randUint64(rng)
 	return &CSC{
 		BucketPow:              bucketPow,
 		NumBuckets:             numBuckets,
@@ -55,10 +71,25 @@ func NewCSC(bucketPow int, fingerprintSize int, slotNum int, maxKickAttempts int
 		SeedOffset:             seedOffset,
 		PartitionNum:           partitionNum,
 		Partitions:             NewGlobalPartition(partitionNum, seedOffset),
+		rng:                    rng,
+	}
+}
+
+func randUint64(rng *rand.Rand) uint64 {
+	if rng == nil {
+		return rand.Uint64()
 	}
+	return rng.Uint64()
 }
 
-// Double 时会清空 Buckets 和 Partitions
+func (csc *CSC) randIntn(n int) int {
+	if csc.rng == nil {
+		return rand.Intn(n)
+	}
+	return csc.rng.Intn(n)
+}
+
+// Double 时会清空 Buckets 和 Partitions，需要保留已加入元素时使用 Grow
 func (csc *CSC) Double() {
 	csc.BucketPow++
 	csc.NumBuckets = 1 << csc.BucketPow
@@ -68,12 +99,13 @@ func (csc *CSC) Double() {
 			Fingerprints: make([][]byte, csc.SlotNum),
 		}
 		for j := range newBuckets[i].Fingerprints {
-			newBuckets[i].Fingerprints[j] = make([]byte, csc.FingerprintSize)
+			newBuckets[i].Fingerprints[j] = make([]byte, csc.FingerprintByteArrSize)
 		}
 	}
 	csc.Buckets = newBuckets
 	csc.Mask = (1 << csc.BucketPow) - 1
 	csc.Partitions.Clear()
+	csc.Stash = nil
 	csc.Utilization_count = 0
 }
 
@@ -92,7 +124,11 @@ func NewEmptyCSC() *CSC {
 
 // 返回一个两倍大小的 CSC
 func (csc *CSC) DoubleSize() *CSC {
-	return NewCSC(csc.BucketPow+1, csc.FingerprintSize, csc.SlotNum, csc.MaxKickAttempts, csc.PartitionNum)
+	doubled := NewCSCWithRand(csc.BucketPow+1, csc.FingerprintSize, csc.SlotNum, csc.MaxKickAttempts, csc.PartitionNum, csc.rng)
+	if csc.RefCounted {
+		doubled.EnableRefCount()
+	}
+	return doubled
 }
 
 func (csc *CSC) IsEmpty() bool {
@@ -116,17 +152,19 @@ func (csc *CSC) Offset(item string) int {
 }
 
 func (csc *CSC) Fingerprint(item string) uint64 {
-	h := xxhash.New()
-	h.Write([]byte(item))
-	// return h.Sum(nil)[:csc.FingerprintSize]
-	return h.Sum64() >> (64 - csc.FingerprintSize)
+	return csc.FingerprintWithLength(item, csc.FingerprintSize)
 }
 
 // 返回一个长度为 length 的 fingerprint
 func (csc *CSC) FingerprintWithLength(item string, length int) uint64 {
-	h := xxhash.New()
+	h := xxhash.NewWithSeed(csc.SeedFingerprint)
 	h.Write([]byte(item))
-	return h.Sum64() >> (64 - length)
+	fp := h.Sum64() >> (64 - length)
+	// 全 0 的 slot 表示空，fp 为 0 时映射为 1，避免假阴
+	if fp == 0 {
+		fp = 1
+	}
+	return fp
 }
 
 // CSC 的 GetIndex 方法需要接受两个参数，一个是 item，一个是 fileId
@@ -181,22 +219,41 @@ func isEmptySlot(f []byte) bool {
 	return true
 }
 
+// 开启扩容后 Add 会保留加入的元素，踢出失败时翻倍并重新插入。
+// 达到翻倍上限时放弃本次加入的元素，重新插入之前的元素后返回 false
 func (csc *CSC) Add(item string, fildId string) bool {
-	// 添加到 GlobalPartition
+	if !csc.growable {
+		return csc.insert(item, fildId)
+	}
+	csc.entries = append(csc.entries, cscEntry{item: item, fileId: fildId})
+	if csc.insert(item, fildId) || csc.Grow() {
+		return true
+	}
+	csc.undo(item, fildId)
+	return false
+}
+
+func (csc *CSC) insert(item string, fildId string) bool {
+	// 指纹放置成功后才添加到 GlobalPartition，失败时不会多记一次引用
+	if !csc.place(item, fildId) {
+		return false
+	}
 	csc.Partitions.Add(fildId)
+	return true
+}
 
+func (csc *CSC) place(item string, fildId string) bool {
 	fingerprint := csc.Fingerprint(item)
 	fingerprint_byte := csc.Uint64ToBytes(fingerprint)
 
 	bucketIndex := csc.GetIndex(item, fildId)
 	altBucketIndex := csc.GetAltIndex(bucketIndex, fingerprint_byte)
 
-	if csc.contains(bucketIndex, fingerprint_byte) {
-		return true
-	}
-
-	if csc.contains(altBucketIndex, fingerprint_byte) {
-		return true
+	// 引用计数模式下相同的指纹需要分别存放，删除其中一个时另一个仍然存在
+	if !csc.RefCounted {
+		if csc.lookup(bucketIndex, altBucketIndex, fingerprint_byte) {
+			return true
+		}
 	}
 
 	emptyFlag := csc.hasEmpty(bucketIndex)
@@ -229,20 +286,25 @@ func (csc *CSC) Add(item string, fildId string) bool {
 
 	// 若两个 bucket 都满了，则需要随机找一个 bucket 中的 slot 进行替换
 	selectBucketIndex := bucketIndex
-	if rand.Intn(2) == 1 {
+	if csc.randIntn(2) == 1 {
 		selectBucketIndex = altBucketIndex
 	}
 
-	tmpFingerprint := csc.swap(selectBucketIndex, rand.Intn(csc.SlotNum), fingerprint_byte)
+	tmpFingerprint := csc.swap(selectBucketIndex, csc.randIntn(csc.SlotNum), fingerprint_byte)
 	kickCount := 1
 	altBucketIndex = csc.GetAltIndex(selectBucketIndex, tmpFingerprint)
 
 	emptyFlag = csc.hasEmpty(altBucketIndex)
 	for emptyFlag == -1 {
-		tmpFingerprint = csc.swap(altBucketIndex, rand.Intn(csc.SlotNum), tmpFingerprint)
+		tmpFingerprint = csc.swap(altBucketIndex, csc.randIntn(csc.SlotNum), tmpFingerprint)
 		altBucketIndex = csc.GetAltIndex(altBucketIndex, tmpFingerprint)
 		kickCount++
 		if kickCount > csc.MaxKickAttempts {
+			// 最后被踢出的指纹放入 stash，stash 已满时该指纹丢失，需要调用方扩容
+			if csc.stash(tmpFingerprint, altBucketIndex) {
+				csc.Utilization_count++
+				return true
+			}
 			return false
 		}
 		emptyFlag = csc.hasEmpty(altBucketIndex)
@@ -261,7 +323,7 @@ func (cf *CSC) Get(item string) []string {
 	for offset := 0; offset < cf.PartitionNum; offset++ {
 		index := (anchor + offset) & cf.Mask
 		altIndex := cf.GetAltIndex(index, fp_byte)
-		if cf.contains(index, fp_byte) || cf.contains(altIndex, fp_byte) {
+		if cf.lookup(index, altIndex, fp_byte) {
 			result = append(result, cf.Partitions.Get(offset)...)
 		}
 	}
@@ -269,6 +331,12 @@ func (cf *CSC) Get(item string) []string {
 }
 
 func (cf *CSC) GetWithCache(item string, cache *CSCCache) []string {
+	result, _ := cf.GetWithCacheCount(item, cache)
+	return result
+}
+
+// 与 GetWithCache 相同，同时返回匹配的候选分区数量
+func (cf *CSC) GetWithCacheCount(item string, cache *CSCCache) ([]string, int) {
 	var fp_byte []byte
 	if ok, tmp_fp_byte := cache.getFingerprintByte(); ok {
 		fp_byte = tmp_fp_byte
@@ -279,14 +347,16 @@ func (cf *CSC) GetWithCache(item string, cache *CSCCache) []string {
 	}
 	anchor := cf.AnchorWithCache(item, cache)
 	result := make([]string, 0)
+	candidates := 0
 	for offset := 0; offset < cf.PartitionNum; offset++ {
 		index := (anchor + offset) & cf.Mask
 		altIndex := cf.GetAltIndexWithCache(index, fp_byte, cache)
-		if cf.contains(index, fp_byte) || cf.contains(altIndex, fp_byte) {
+		if cf.lookup(index, altIndex, fp_byte) {
 			result = append(result, cf.Partitions.Get(offset)...)
+			candidates++
 		}
 	}
-	return result
+	return result, candidates
 	// fp := cf.Fingerprint(item)
 	// fp_byte := cf.Uint64ToBytes(fp)
 	// anchor := cf.Anchor(item)
@@ -362,8 +432,10 @@ func NewCSCWithCache(bucketPow int, fingerprintSize int, slotNum int, maxKickAtt
 		MaxKickAttempts:        maxKickAttempts,
 		SeedAnchor:             seedAnchor,
 		SeedOffset:             seedOffset,
+		SeedFingerprint:        cscCache.SeedFingerprint,
 		PartitionNum:           partitionNum,
 		Partitions:             NewGlobalPartition(partitionNum, seedOffset),
+		rng:                    cscCache.rng,
 	}
 }
 
@@ -416,3 +488,12 @@ func (csc *CSC) Uint64ToBytes(value uint64) []byte {
 	}
 	return bytes
 }
+
+// 估计查询时某个 fileId 被误报的概率：查询需要检查两个 bucket，其中每个已占用的 slot 都以 2^-f 的概率与查询的 fingerprint 相同
+func (csc *CSC) FalsePositiveRate() float64 {
+	if csc.IsEmpty() || csc.FingerprintSize == 0 {
+		return 0
+	}
+	occupied := float64(csc.Utilization_count) / float64(csc.NumBuckets)
+	return 1 - math.Pow(1-math.Pow(2, -float64(csc.FingerprintSize)), 2*occupied)
+}
@@ -2,6 +2,8 @@ package cscsketch
 
 import (
 	"math/rand"
+
+	"github.com/liuys-dase/csc-tree/filter/basicfilter"
 )
 
 type CSCCacheList struct {
@@ -9,15 +11,35 @@ type CSCCacheList struct {
 }
 
 func NewCSCCacheList(repetitionNum int) *CSCCacheList {
+	return NewCSCCacheListWithRand(repetitionNum, nil)
+}
+
+// 所有 CSCCache 以及通过它们创建的 CSC 共用 rng
+func NewCSCCacheListWithRand(repetitionNum int, rng *rand.Rand) *CSCCacheList {
 	cscCacheList := make([]*CSCCache, repetitionNum)
 	for i := range cscCacheList {
-		cscCacheList[i] = NewCSCCache()
+		cscCacheList[i] = NewCSCCacheWithRand(rng)
 	}
 	return &CSCCacheList{
 		CSCCacheList: cscCacheList,
 	}
 }
 
+// 使用由密钥派生的种子，第 i 个 CSCCache 的种子互不相同；key 为空时与 NewCSCCacheListWithRand 相同。
+// rng 只用于踢出
+func NewCSCCacheListWithKey(repetitionNum int, rng *rand.Rand, key string) *CSCCacheList {
+	cacheList := NewCSCCacheListWithRand(repetitionNum, rng)
+	if key == "" {
+		return cacheList
+	}
+	for i, cache := range cacheList.CSCCacheList {
+		cache.SeedAnchor = basicfilter.KeyedSeed(key, "anchor", i)
+		cache.SeedOffset = basicfilter.KeyedSeed(key, "offset", i)
+		cache.SeedFingerprint = basicfilter.KeyedSeed(key, "fingerprint", i)
+	}
+	return cacheList
+}
+
 func (cacheList *CSCCacheList) Clear() {
 	for _, cache := range cacheList.CSCCacheList {
 		cache.Clear()
@@ -26,22 +48,30 @@ func (cacheList *CSCCacheList) Clear() {
 
 // 记录多个 csccache 可共用的中间计算结果
 type CSCCache struct {
-	// SeedAnchor 和 SeedOffset 不可变
-	SeedAnchor uint64
-	SeedOffset uint64
+	// SeedAnchor、SeedOffset 和 SeedFingerprint 不可变
+	SeedAnchor      uint64
+	SeedOffset      uint64
+	SeedFingerprint uint64
 	// 下面的内容随着查询键的变化改变
 	FingerprintByte     []byte
 	FingerprintByteHash uint64
 	HashItem            uint64
+	// 通过该 CSCCache 创建的 CSC 使用的随机数来源，为 nil 时使用全局的 rand
+	rng *rand.Rand
 }
 
 func NewCSCCache() *CSCCache {
+	return NewCSCCacheWithRand(nil)
+}
+
+func NewCSCCacheWithRand(rng *rand.Rand) *CSCCache {
 	return &CSCCache{
-		SeedAnchor:          rand.Uint64(),
-		SeedOffset:          rand.Uint64(),
+		SeedAnchor:          randUint64(rng),
+		SeedOffset:          randUint64(rng),
 		FingerprintByte:     nil,
 		FingerprintByteHash: 0,
 		HashItem:            0,
+		rng:                 rng,
 	}
 }
 
@@ -1,7 +1,9 @@
 package cscsketch
 
 import (
+	"fmt"
 	"math"
+	"sort"
 	"strconv"
 )
 
@@ -19,6 +21,8 @@ type CSCR struct {
 	CSCs    []*CSC
 	HashMap map[string][]string
 	R       int
+	// 用于统计 Double 的次数，开启扩容时为所有 CSC 扩容次数之和
+	DoubleCount int
 }
 
 // HashMap
@@ -63,29 +67,42 @@ func NewEmptyCSCR() *CSCR {
 	return NewCSCR(0, 0, 0, 0, 0, 0)
 }
 
-// 批量添加元素
-func (cscr *CSCR) BatchAdd(kvs map[string]int) {
-	retry_flag := true
-	for retry_flag {
-		for key, value := range kvs {
-			if !cscr.Add(key, strconv.Itoa(value)) {
-				cscr.Double()
-				retry_flag = true
-				break
-			}
-			retry_flag = false
+// 批量添加元素，按 key 的顺序插入，保证相同的随机数来源得到相同的结果。
+// 插入期间开启扩容，某个 CSC 空间不足时只扩容该 CSC，不会重新开始整个批次。
+// 扩容达到上限仍然放不下的元素不会留在任何一个 CSC 中，此时返回 ErrFull，调用方需要改用其他结构保存
+func (cscr *CSCR) BatchAdd(kvs map[string]int) error {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cscr.EnableGrowth()
+	failed := 0
+	for _, key := range keys {
+		if !cscr.Add(key, strconv.Itoa(kvs[key])) {
+			failed++
 		}
 	}
+	cscr.Seal()
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d items not added", ErrFull, failed, len(kvs))
+	}
+	return nil
 }
 
 func (cscr *CSCR) IsEmpty() bool {
 	return cscr.R == 0
 }
 
+// 开启扩容时插入是原子的：某个 CSC 放不下时撤销之前的 CSC 中的插入，元素不会只存在于部分 CSC 中
 func (cscr *CSCR) Add(item string, fileId string) bool {
 	if cscr.CType == SKETCH {
 		for i := range cscr.CSCs {
-			if !cscr.CSCs[i].Add(item, fileId) {
+			doubles := cscr.CSCs[i].doubles
+			ok := cscr.CSCs[i].Add(item, fileId)
+			cscr.DoubleCount += cscr.CSCs[i].doubles - doubles
+			if !ok {
+				cscr.undo(i, item, fileId)
 				return false
 			}
 		}
@@ -105,7 +122,17 @@ func (cscr *CSCR) Add(item string, fileId string) bool {
 	}
 }
 
+// 撤销前 n 个 CSC 中刚加入的 (item, fileId)
+func (cscr *CSCR) undo(n int, item string, fileId string) {
+	for _, csc := range cscr.CSCs[:n] {
+		doubles := csc.doubles
+		csc.undo(item, fileId)
+		cscr.DoubleCount += csc.doubles - doubles
+	}
+}
+
 func (cscr *CSCR) Double() {
+	cscr.DoubleCount++
 	for i := range cscr.CSCs {
 		cscr.CSCs[i].Double()
 	}
@@ -135,25 +162,33 @@ func (cscr *CSCR) Get(item string) []string {
 }
 
 func (cscr *CSCR) GetWithCache(item string, cacheList *CSCCacheList) []string {
+	result, _ := cscr.GetWithCacheCount(item, cacheList)
+	return result
+}
+
+// 与 GetWithCache 相同，同时返回所有 CSC 中匹配的候选分区数量之和（HashMap 为 0）
+func (cscr *CSCR) GetWithCacheCount(item string, cacheList *CSCCacheList) ([]string, int) {
 	result := make([]string, 0)
+	candidates := 0
 	if cscr.CType == SKETCH {
 		if cscr.IsEmpty() {
-			return result
+			return result, 0
 		}
 		for i := range cscr.CSCs {
+			part_res, count := cscr.CSCs[i].GetWithCacheCount(item, cacheList.CSCCacheList[i])
+			candidates += count
 			if i == 0 {
-				result = cscr.CSCs[i].GetWithCache(item, cacheList.CSCCacheList[i])
+				result = part_res
 			} else {
-				part_res := cscr.CSCs[i].GetWithCache(item, cacheList.CSCCacheList[i])
 				result = intersect(result, part_res)
 			}
 		}
-		return result
+		return result, candidates
 	} else {
 		if _, ok := cscr.HashMap[item]; !ok {
-			return result
+			return result, 0
 		}
-		return cscr.HashMap[item]
+		return cscr.HashMap[item], 0
 	}
 }
 
@@ -235,3 +270,15 @@ func (cscr *CSCR) GetUtilizationCount() int {
 	}
 	return total_utilization / cscr.R
 }
+
+// 估计误报概率，R 个 CSC 的结果取交集，因此需要每个 CSC 都误报；HashMap 不会误报
+func (cscr *CSCR) FalsePositiveRate() float64 {
+	if cscr.R == 0 || cscr.CType == HASHMAP {
+		return 0
+	}
+	rate := 1.0
+	for i := range cscr.CSCs {
+		rate *= cscr.CSCs[i].FalsePositiveRate()
+	}
+	return rate
+}
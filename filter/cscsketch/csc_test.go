@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/liuys-dase/csc-tree/filter/basicfilter"
 	"github.com/stretchr/testify/assert"
 )
@@ -199,3 +200,37 @@ func generateConflictKeys(cf *basicfilter.CuckooFilter, count int, k int) []stri
 func TestDivide(t *testing.T) {
 	fmt.Printf("test: %v", math.Ceil(float64(25)/float64(8)))
 }
+
+func TestCSCWithRandIsReproducible(t *testing.T) {
+	build := func(seed int64) *CSC {
+		csc := NewCSCWithRand(4, 8, 4, 500, 4, rand.New(rand.NewSource(seed)))
+		// 元素数量接近容量，保证发生踢出
+		for i, key := range generateRandomStrings(20, 60, rand.New(rand.NewSource(1))) {
+			csc.Add(key, fmt.Sprint(i%4))
+		}
+		return csc
+	}
+	c1, c2 := build(7), build(7)
+	assert.Equal(t, c1.SeedAnchor, c2.SeedAnchor)
+	assert.Equal(t, c1.Buckets, c2.Buckets)
+	assert.Equal(t, c1.Utilization_count, c2.Utilization_count)
+	assert.NotEqual(t, c1.SeedAnchor, build(8).SeedAnchor)
+}
+
+// 指纹哈希为 0 的 key 映射为 1，不会与空 slot 混淆
+func TestCSCZeroFingerprint(t *testing.T) {
+	csc := NewCSC(10, 4, 4, 10, 10)
+	item := ""
+	for i := 0; ; i++ {
+		item = fmt.Sprintf("0x%d", i)
+		h := xxhash.NewWithSeed(csc.SeedFingerprint)
+		h.Write([]byte(item))
+		if h.Sum64()>>60 == 0 {
+			break
+		}
+	}
+	assert.Equal(t, uint64(1), csc.Fingerprint(item))
+	assert.Equal(t, uint64(1), csc.FingerprintWithLength(item, 4))
+	assert.True(t, csc.Add(item, "file1"))
+	assert.Equal(t, []string{"file1"}, csc.Get(item))
+}
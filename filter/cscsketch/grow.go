@@ -0,0 +1,99 @@
+package cscsketch
+
+import "errors"
+
+// 扩容达到上限后仍然无法插入
+var ErrFull = errors.New("csc is full after growing")
+
+// 一次 Grow 最多翻倍的次数。引用计数模式下同一个 (item, fileId) 的指纹只能放在两个 bucket 与 stash 中，
+// 超过 2 * SlotNum + StashSize 份时无论扩容多少次都放不下
+const MaxGrowDoubles = 8
+
+// 构建期间保留的 (item, fileId)
+type cscEntry struct {
+	item   string
+	fileId string
+}
+
+// 开始保留加入的 (item, fileId)：之后 Add 踢出失败时只扩容当前 CSC 并重新插入已加入的元素，
+// 不需要调用方清空后重新插入整个批次。构建完成后调用 Seal 释放
+func (csc *CSC) EnableGrowth() {
+	csc.growable = true
+}
+
+// 释放构建期间保留的 (item, fileId)，之后 Add 失败时重新返回 false
+func (csc *CSC) Seal() {
+	csc.growable = false
+	csc.entries = nil
+}
+
+// 将 bucket 数量翻倍并重新插入保留的所有 (item, fileId)，重新插入失败时继续翻倍，最多 MaxGrowDoubles 次。
+// 指纹不包含 anchor + offset 的高位，无法直接搬到两倍大小的表中，因此需要保留原始的 key。
+// 未开启扩容、没有 slot 或达到翻倍上限时返回 false
+func (csc *CSC) Grow() bool {
+	if !csc.growable || csc.SlotNum == 0 {
+		return false
+	}
+	for i := 0; i < MaxGrowDoubles; i++ {
+		csc.Double()
+		csc.doubles++
+		if csc.reinsert() {
+			return true
+		}
+	}
+	return false
+}
+
+// 保持当前大小，清空后重新插入保留的所有 (item, fileId)
+func (csc *CSC) rebuild() bool {
+	for _, bucket := range csc.Buckets {
+		for j := range bucket.Fingerprints {
+			bucket.Fingerprints[j] = make([]byte, csc.FingerprintByteArrSize)
+		}
+	}
+	csc.Partitions.Clear()
+	csc.Stash = nil
+	csc.Utilization_count = 0
+	return csc.reinsert()
+}
+
+func (csc *CSC) reinsert() bool {
+	for _, e := range csc.entries {
+		if !csc.insert(e.item, e.fileId) {
+			return false
+		}
+	}
+	return true
+}
+
+// 删除最后一次保留的 (item, fileId)，避免扩容时重新插入已经删除的元素
+func (csc *CSC) forget(item string, fileId string) {
+	for i := len(csc.entries) - 1; i >= 0; i-- {
+		if csc.entries[i].item == item && csc.entries[i].fileId == fileId {
+			csc.entries = append(csc.entries[:i], csc.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// 撤销最后一次加入的 (item, fileId)：不再保留该元素，按当前大小重建，重建失败时继续扩容。
+// 未开启扩容时无法撤销，返回 false
+func (csc *CSC) undo(item string, fileId string) bool {
+	if !csc.growable {
+		return false
+	}
+	csc.forget(item, fileId)
+	return csc.rebuild() || csc.Grow()
+}
+
+func (cscr *CSCR) EnableGrowth() {
+	for _, csc := range cscr.CSCs {
+		csc.EnableGrowth()
+	}
+}
+
+func (cscr *CSCR) Seal() {
+	for _, csc := range cscr.CSCs {
+		csc.Seal()
+	}
+}
@@ -0,0 +1,104 @@
+package cscsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSCGrow(t *testing.T) {
+	csc := NewCSCWithRand(1, 12, 4, 30, 4, rand.New(rand.NewSource(1)))
+	assert.False(t, csc.Grow())
+	csc.EnableGrowth()
+	assert.Nil(t, csc.EnableRefCount())
+	for i := 0; i < 500; i++ {
+		assert.True(t, csc.Add(fmt.Sprintf("0x%04d", i), strconv.Itoa(i%10)))
+	}
+	assert.True(t, csc.doubles > 0)
+	assert.Equal(t, 500, csc.Utilization_count)
+	// 删除的元素在扩容后不会重新出现
+	assert.Nil(t, csc.Remove("0x0000", "0"))
+	assert.True(t, csc.Grow())
+	assert.Equal(t, 499, csc.Utilization_count)
+	for i := 1; i < 500; i++ {
+		assert.Contains(t, csc.Get(fmt.Sprintf("0x%04d", i)), strconv.Itoa(i%10))
+	}
+	csc.Seal()
+	assert.Nil(t, csc.entries)
+	assert.False(t, csc.Grow())
+}
+
+// 同一个 (item, fileId) 超过两个 bucket 与 stash 的容量时，扩容有上限并返回 false
+func TestCSCGrowDuplicateFlood(t *testing.T) {
+	csc := NewCSCWithRand(1, 12, 4, 30, 4, rand.New(rand.NewSource(1)))
+	assert.Nil(t, csc.EnableRefCount())
+	csc.EnableGrowth()
+	// 两个候选 bucket 相同时容量只有 SlotNum + StashSize
+	added, doubles := 0, 0
+	for added <= 2*csc.SlotNum+StashSize {
+		doubles = csc.doubles
+		if !csc.Add("0x0001", "1") {
+			break
+		}
+		added++
+	}
+	assert.True(t, added >= csc.SlotNum+StashSize && added <= 2*csc.SlotNum+StashSize)
+	assert.Equal(t, MaxGrowDoubles, csc.doubles-doubles)
+	// 放弃的元素不会保留，之前加入的元素仍然存在
+	assert.Equal(t, added, len(csc.entries))
+	assert.Equal(t, added, csc.Utilization_count)
+	assert.Equal(t, added, csc.Partitions.RefCount("1"))
+	assert.Equal(t, []string{"1"}, csc.Get("0x0001"))
+	for i := 0; i < added; i++ {
+		assert.Nil(t, csc.Remove("0x0001", "1"))
+	}
+	assert.Equal(t, ErrNotFound, csc.Remove("0x0001", "1"))
+}
+
+// 空间不足时只扩容对应的 CSC，不会清空整个 CSCR
+func TestCSCRBatchAddGrows(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cscr := NewCSCRWithEstimationWithCache(10, 12, 4, 30, 4, 3, NewCSCCacheListWithRand(3, rng))
+	kvs := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		kvs[fmt.Sprintf("0x%04d", i)] = rng.Intn(30)
+	}
+	assert.Nil(t, cscr.BatchAdd(kvs))
+	assert.True(t, cscr.DoubleCount > 0)
+	for _, csc := range cscr.CSCs {
+		// 相同 bucket 中相同的指纹只存放一次
+		assert.InDelta(t, len(kvs), csc.Utilization_count, 10)
+		assert.Nil(t, csc.entries)
+	}
+	for key, nid := range kvs {
+		assert.Contains(t, cscr.Get(key), strconv.Itoa(nid))
+	}
+}
+
+// 某个 CSC 达到翻倍上限时撤销其他 CSC 中的插入，之前加入的元素仍然可以查到
+func TestCSCRAddAtomic(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cscr := NewCSCRWithEstimationWithCache(10, 12, 4, 30, 4, 3, NewCSCCacheListWithRand(3, rng))
+	assert.Nil(t, cscr.EnableRefCount())
+	cscr.EnableGrowth()
+	for i := 0; i < 20; i++ {
+		assert.True(t, cscr.Add(fmt.Sprintf("0x%04d", i), strconv.Itoa(i%10)))
+	}
+	added := 0
+	for cscr.Add("0x0001", "1") {
+		added++
+	}
+	for _, csc := range cscr.CSCs {
+		assert.Equal(t, 20+added, len(csc.entries))
+		assert.Equal(t, 20+added, csc.Utilization_count)
+		// 0x0001 与 0x0011 的 fileId 都是 1
+		assert.Equal(t, added+2, csc.Partitions.RefCount("1"))
+	}
+	for i := 0; i < 20; i++ {
+		assert.Contains(t, cscr.Get(fmt.Sprintf("0x%04d", i)), strconv.Itoa(i%10))
+	}
+	cscr.Seal()
+}
@@ -1,6 +1,10 @@
 package cscsketch
 
-import "github.com/cespare/xxhash/v2"
+import (
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+)
 
 type GlobalPartition struct {
 	// 用于存储所有的分区
@@ -11,6 +15,10 @@ type GlobalPartition struct {
 
 type Partition struct {
 	Blocks map[string]bool
+	// 与 Blocks 相同的 key，按字典序排列，保证查询结果的顺序固定
+	Keys []string
+	// 引用计数模式下记录每个 key 被加入的次数，为 nil 时不计数
+	Refs map[string]int
 }
 
 func NewPartition() *Partition {
@@ -34,20 +42,64 @@ func NewGlobalPartition(partitionNum int, seed uint64) *GlobalPartition {
 func (p *GlobalPartition) Clear() {
 	for i := range p.Partitions {
 		p.Partitions[i].Blocks = make(map[string]bool)
+		p.Partitions[i].Keys = nil
+		if p.Partitions[i].Refs != nil {
+			p.Partitions[i].Refs = make(map[string]int)
+		}
+	}
+}
+
+// 开启引用计数，只能在没有任何 key 时开启
+func (p *GlobalPartition) EnableRefCount() {
+	for i := range p.Partitions {
+		p.Partitions[i].Refs = make(map[string]int)
 	}
 }
 
+func (p *GlobalPartition) IsRefCounted() bool {
+	return len(p.Partitions) > 0 && p.Partitions[0].Refs != nil
+}
+
 func (par *GlobalPartition) Add(key string) {
 	parId := par.GetPartitionId(key)
-	par.Partitions[parId].Blocks[key] = true
+	p := &par.Partitions[parId]
+	if !p.Blocks[key] {
+		p.Blocks[key] = true
+		i := sort.SearchStrings(p.Keys, key)
+		p.Keys = append(p.Keys, "")
+		copy(p.Keys[i+1:], p.Keys[i:])
+		p.Keys[i] = key
+	}
+	if p.Refs != nil {
+		p.Refs[key]++
+	}
 }
 
-func (par *GlobalPartition) Get(index int) []string {
-	keys := make([]string, 0)
-	for key := range par.Partitions[index].Blocks {
-		keys = append(keys, key)
+// key 的引用次数，未开启引用计数时为 0
+func (par *GlobalPartition) RefCount(key string) int {
+	return par.Partitions[par.GetPartitionId(key)].Refs[key]
+}
+
+// 引用计数减一，计数为 0 时删除 key；未开启引用计数或 key 不存在时返回 false
+func (par *GlobalPartition) Remove(key string) bool {
+	parId := par.GetPartitionId(key)
+	p := &par.Partitions[parId]
+	if p.Refs == nil || p.Refs[key] == 0 {
+		return false
 	}
-	return keys
+	p.Refs[key]--
+	if p.Refs[key] == 0 {
+		delete(p.Refs, key)
+		delete(p.Blocks, key)
+		i := sort.SearchStrings(p.Keys, key)
+		p.Keys = append(p.Keys[:i], p.Keys[i+1:]...)
+	}
+	return true
+}
+
+// 返回分区中有序的 key，调用方不能修改返回的切片
+func (par *GlobalPartition) Get(index int) []string {
+	return par.Partitions[index].Keys
 }
 
 func (par *GlobalPartition) GetPartitionId(key string) int {
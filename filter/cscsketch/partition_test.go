@@ -2,7 +2,10 @@ package cscsketch
 
 import (
 	"fmt"
+	"sort"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestPartitionInsert(t *testing.T) {
@@ -17,3 +20,34 @@ func TestPartitionInsert(t *testing.T) {
 	fmt.Printf("par = %d\n", par.GetPartitionId("hello"))
 	fmt.Printf("par = %d\n", par.GetPartitionId("shit"))
 }
+
+// Get 按字典序返回分区中的 key，删除后保持有序
+func TestPartitionGetSorted(t *testing.T) {
+	par := NewGlobalPartition(4, 1)
+	par.EnableRefCount()
+	keys := generateRandomStrings(8, 200, randomSeed())
+	for _, key := range keys {
+		par.Add(key)
+		par.Add(key)
+	}
+	left := make(map[string]bool)
+	for i, key := range keys {
+		if i%3 == 0 {
+			assert.True(t, par.Remove(key))
+			assert.True(t, par.Remove(key))
+		} else {
+			left[key] = true
+		}
+	}
+	total := 0
+	for i, p := range par.Partitions {
+		got := par.Get(i)
+		assert.True(t, sort.StringsAreSorted(got))
+		assert.Equal(t, len(p.Blocks), len(got))
+		for _, key := range got {
+			assert.True(t, left[key])
+		}
+		total += len(got)
+	}
+	assert.Equal(t, len(left), total)
+}
@@ -0,0 +1,115 @@
+package cscsketch
+
+import "errors"
+
+var (
+	// 非引用计数模式下相同的指纹只存放一次，分区也不记录引用次数，删除可能导致其他元素漏报
+	ErrNotRefCounted = errors.New("remove requires a reference-counted CSC")
+	// 待删除的 (item, fileId) 不存在
+	ErrNotFound = errors.New("item not found")
+	// CSC 中已经有元素，无法再开启引用计数
+	ErrNotEmpty = errors.New("reference counting must be enabled before any Add")
+)
+
+// 开启引用计数模式，只能在第一次 Add 之前调用
+func (csc *CSC) EnableRefCount() error {
+	if csc.Utilization_count > 0 {
+		return ErrNotEmpty
+	}
+	csc.RefCounted = true
+	csc.Partitions.EnableRefCount()
+	return nil
+}
+
+// 查找 (item, fileId) 的指纹所在的 bucket 与 slot，bucket 为 -1 时 slot 为 stash 中的位置，不存在时返回 -1, -1
+func (csc *CSC) find(item string, fileId string) (int, int) {
+	if csc.IsEmpty() {
+		return -1, -1
+	}
+	fingerprint_byte := csc.Uint64ToBytes(csc.Fingerprint(item))
+	bucketIndex := csc.GetIndex(item, fileId)
+	altBucketIndex := csc.GetAltIndex(bucketIndex, fingerprint_byte)
+	for _, index := range []int{bucketIndex, altBucketIndex} {
+		for slot, f := range csc.Buckets[index].Fingerprints {
+			if string(f) == string(fingerprint_byte) && !isEmptySlot(f) {
+				return index, slot
+			}
+		}
+	}
+	return -1, csc.stashIndex(bucketIndex, altBucketIndex, fingerprint_byte)
+}
+
+// 删除一次之前加入的 (item, fileId)：清除 bucket、备用 bucket 或 stash 中的一个指纹，并将 fileId 的引用次数减一，
+// 没有指纹引用 fileId 时将其从分区中删除。只有之前确实加入过的 (item, fileId) 才能删除
+func (csc *CSC) Remove(item string, fileId string) error {
+	if !csc.RefCounted {
+		return ErrNotRefCounted
+	}
+	bucket, slot := csc.find(item, fileId)
+	if slot == -1 || !csc.Partitions.Remove(fileId) {
+		return ErrNotFound
+	}
+	if bucket == -1 {
+		csc.Stash = append(csc.Stash[:slot], csc.Stash[slot+1:]...)
+	} else {
+		csc.Buckets[bucket].Fingerprints[slot] = make([]byte, csc.FingerprintByteArrSize)
+		csc.drainStash()
+	}
+	csc.Utilization_count--
+	if csc.growable {
+		csc.forget(item, fileId)
+	}
+	return nil
+}
+
+// 开启所有 CSC 的引用计数，HashMap 类型的 CSCR 本身就支持删除
+func (cscr *CSCR) EnableRefCount() error {
+	for _, csc := range cscr.CSCs {
+		if err := csc.EnableRefCount(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 从每个 CSC（或 HashMap）中删除 (item, fileId)，任意一个 CSC 中找不到时不做任何修改
+func (cscr *CSCR) Remove(item string, fileId string) error {
+	if cscr.CType == HASHMAP {
+		return cscr.removeFromHashMap(item, fileId)
+	}
+	if cscr.IsEmpty() {
+		return ErrNotFound
+	}
+	for _, csc := range cscr.CSCs {
+		if !csc.RefCounted {
+			return ErrNotRefCounted
+		}
+		if _, slot := csc.find(item, fileId); slot == -1 || csc.Partitions.RefCount(fileId) == 0 {
+			return ErrNotFound
+		}
+	}
+	for _, csc := range cscr.CSCs {
+		if err := csc.Remove(item, fileId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cscr *CSCR) removeFromHashMap(item string, fileId string) error {
+	fileIds := cscr.HashMap[item]
+	for i, val := range fileIds {
+		if val == fileId {
+			if len(fileIds) == 1 {
+				delete(cscr.HashMap, item)
+				return nil
+			}
+			// Get 直接返回了内部的切片，这里不修改原切片
+			rest := make([]string, 0, len(fileIds)-1)
+			rest = append(rest, fileIds[:i]...)
+			cscr.HashMap[item] = append(rest, fileIds[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,96 @@
+package cscsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSCRemove(t *testing.T) {
+	csc := NewCSCWithRand(6, 8, 4, 30, 8, rand.New(rand.NewSource(1)))
+	csc.Add("0xa", "1")
+	assert.ErrorIs(t, csc.Remove("0xa", "1"), ErrNotRefCounted)
+	assert.ErrorIs(t, csc.EnableRefCount(), ErrNotEmpty)
+
+	csc = NewCSCWithRand(6, 8, 4, 30, 8, rand.New(rand.NewSource(1)))
+	assert.Nil(t, csc.EnableRefCount())
+	// 同一个分区中的两个 fileId：相同的 item 得到相同的 bucket 与指纹，需要分别存放
+	file1 := "1"
+	file2 := ""
+	for i := 2; file2 == ""; i++ {
+		if csc.Partitions.GetPartitionId(strconv.Itoa(i)) == csc.Partitions.GetPartitionId(file1) {
+			file2 = strconv.Itoa(i)
+		}
+	}
+	assert.True(t, csc.Add("0xa", file1))
+	assert.True(t, csc.Add("0xa", file2))
+	assert.Equal(t, 2, csc.Utilization_count)
+	assert.Nil(t, csc.Remove("0xa", file1))
+	assert.ElementsMatch(t, []string{file2}, csc.Get("0xa"))
+	assert.ErrorIs(t, csc.Remove("0xa", file1), ErrNotFound)
+	assert.Nil(t, csc.Remove("0xa", file2))
+	assert.Empty(t, csc.Get("0xa"))
+	assert.Equal(t, 0, csc.Utilization_count)
+	assert.Equal(t, 0, csc.Partitions.RefCount(file2))
+}
+
+func TestCSCRRemove(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cscr := NewCSCRWithEstimationWithCache(400, 12, 4, 100, 8, 2, NewCSCCacheListWithRand(2, rng))
+	assert.Nil(t, cscr.EnableRefCount())
+	pairs := make([][2]string, 0)
+	for i := 0; i < 400; i++ {
+		pair := [2]string{fmt.Sprintf("0x%04d", i), strconv.Itoa(rng.Intn(20))}
+		assert.True(t, cscr.Add(pair[0], pair[1]))
+		pairs = append(pairs, pair)
+	}
+	removed := make(map[string]int)
+	for i, pair := range pairs {
+		if i%2 == 0 {
+			assert.Nil(t, cscr.Remove(pair[0], pair[1]))
+			removed[pair[1]]++
+		}
+	}
+	// 剩余的元素没有漏报
+	remaining := make(map[string]int)
+	for i, pair := range pairs {
+		if i%2 == 1 {
+			assert.Contains(t, cscr.Get(pair[0]), pair[1])
+			remaining[pair[1]]++
+		}
+	}
+	assert.Equal(t, 200, cscr.GetUtilizationCount())
+	// 只被删除的元素引用的 fileId 已经从分区中删除
+	for fileId := range removed {
+		for _, csc := range cscr.CSCs {
+			assert.Equal(t, remaining[fileId], csc.Partitions.RefCount(fileId))
+		}
+	}
+	assert.ErrorIs(t, cscr.Remove("0x9999", "1"), ErrNotFound)
+
+	hm := NewCSCRWithHashMap()
+	hm.Add("0xa", "1")
+	hm.Add("0xa", "2")
+	res := hm.Get("0xa")
+	assert.Nil(t, hm.Remove("0xa", "1"))
+	assert.Equal(t, []string{"1", "2"}, res)
+	assert.Equal(t, []string{"2"}, hm.Get("0xa"))
+	assert.Nil(t, hm.Remove("0xa", "2"))
+	assert.ErrorIs(t, hm.Remove("0xa", "2"), ErrNotFound)
+	assert.Empty(t, hm.HashMap)
+}
+
+// 插入失败时不增加分区的引用计数
+func TestCSCRefCountOnFailure(t *testing.T) {
+	csc := NewCSCWithRand(1, 12, 4, 30, 4, rand.New(rand.NewSource(1)))
+	assert.Nil(t, csc.EnableRefCount())
+	added := 0
+	for csc.Add("0x0001", "1") {
+		added++
+	}
+	assert.Equal(t, added, csc.Utilization_count)
+	assert.Equal(t, added, csc.Partitions.RefCount("1"))
+}
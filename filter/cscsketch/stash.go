@@ -0,0 +1,51 @@
+package cscsketch
+
+// 每个 CSC 的 stash 容量，踢出失败超过该次数后 Add 才返回 false
+const StashSize = 4
+
+// stash 中的指纹以及它的一个候选 bucket，另一个候选 bucket 由 GetAltIndex 得到
+type StashEntry struct {
+	Fingerprint []byte
+	Bucket      int
+}
+
+// 将指纹放入 stash，已满时返回 false
+func (csc *CSC) stash(fingerprint_byte []byte, bucket_index int) bool {
+	if len(csc.Stash) >= StashSize {
+		return false
+	}
+	csc.Stash = append(csc.Stash, StashEntry{Fingerprint: fingerprint_byte, Bucket: bucket_index})
+	return true
+}
+
+// 候选 bucket 为 index 或 altIndex 的指纹在 stash 中的位置，不存在时返回 -1
+func (csc *CSC) stashIndex(index int, altIndex int, fingerprint_byte []byte) int {
+	for i, e := range csc.Stash {
+		if (e.Bucket == index || e.Bucket == altIndex) && string(e.Fingerprint) == string(fingerprint_byte) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 两个候选 bucket 或 stash 中是否包含 fingerprint
+func (csc *CSC) lookup(index int, altIndex int, fingerprint_byte []byte) bool {
+	return csc.contains(index, fingerprint_byte) || csc.contains(altIndex, fingerprint_byte) ||
+		csc.stashIndex(index, altIndex, fingerprint_byte) != -1
+}
+
+// 删除后 bucket 中可能有了空位，将 stash 中的指纹移回 bucket
+func (csc *CSC) drainStash() {
+	rest := csc.Stash[:0]
+	for _, e := range csc.Stash {
+		alt := csc.GetAltIndex(e.Bucket, e.Fingerprint)
+		if slot := csc.hasEmpty(e.Bucket); slot != -1 {
+			csc.Buckets[e.Bucket].Fingerprints[slot] = e.Fingerprint
+		} else if slot := csc.hasEmpty(alt); slot != -1 {
+			csc.Buckets[alt].Fingerprints[slot] = e.Fingerprint
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	csc.Stash = rest
+}
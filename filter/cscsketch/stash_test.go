@@ -0,0 +1,46 @@
+package cscsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 踢出失败的指纹进入 stash，stash 未满时已经加入的元素不会漏报
+func TestCSCStash(t *testing.T) {
+	csc := NewCSCWithRand(2, 16, 2, 5, 1, rand.New(rand.NewSource(1)))
+	added := make([]string, 0)
+	for i := 0; len(csc.Stash) < StashSize; i++ {
+		item := fmt.Sprintf("0x%04d", i)
+		assert.True(t, csc.Add(item, "1"))
+		added = append(added, item)
+	}
+	assert.Equal(t, len(added), csc.Utilization_count)
+	for _, item := range added {
+		assert.Equal(t, []string{"1"}, csc.Get(item), item)
+	}
+	// stash 已满后再次踢出失败才返回 false
+	failed := false
+	for i := len(added); !failed && i < 1000; i++ {
+		failed = !csc.Add(fmt.Sprintf("0x%04d", i), "1")
+	}
+	assert.True(t, failed)
+	assert.Equal(t, StashSize, len(csc.Stash))
+
+	// 删除后 stash 中的指纹移回 bucket
+	csc = NewCSCWithRand(2, 16, 2, 5, 1, rand.New(rand.NewSource(1)))
+	assert.Nil(t, csc.EnableRefCount())
+	added = added[:0]
+	for i := 0; len(csc.Stash) == 0; i++ {
+		item := fmt.Sprintf("0x%04d", i)
+		assert.True(t, csc.Add(item, "1"))
+		added = append(added, item)
+	}
+	assert.Nil(t, csc.Remove(added[0], "1"))
+	assert.Empty(t, csc.Stash)
+	for _, item := range added[1:] {
+		assert.Equal(t, []string{"1"}, csc.Get(item), item)
+	}
+}
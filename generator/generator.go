@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/liuys-dase/csc-tree/config"
+)
+
+// 生成器的参数，所有随机性都来自 Seed，相同的参数总是生成相同的数据
+type Config struct {
+	Seed         int64
+	StartBlock   int     // 第一个区块的区块号
+	TxnsPerBlock int     // 每个区块的交易数量
+	Accounts     int     // 普通账户的数量，发送方与接收方服从 Zipf 分布
+	ZipfS        float64 // Zipf 分布的参数 s，必须大于 1，越大越集中
+	ZipfV        float64 // Zipf 分布的参数 v，必须不小于 1
+	HotAccounts  int     // 交易所等热点账户的数量
+	HotFraction  float64 // 交易的发送方（或接收方）为热点账户的概率
+	NewAccount   float64 // 交易的发送方为新账户的概率
+	BurstProb    float64 // 区块处于爆发期的概率
+	BurstAccount float64 // 爆发期内交易的发送方为新账户的概率
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Seed:         1,
+		StartBlock:   0,
+		TxnsPerBlock: 100,
+		Accounts:     10000,
+		ZipfS:        1.2,
+		ZipfV:        1,
+		HotAccounts:  5,
+		HotFraction:  0.1,
+		NewAccount:   0.02,
+		BurstProb:    0.05,
+		BurstAccount: 0.5,
+	}
+}
+
+// 校验参数，返回所有不合法的参数（可以通过 errors.As 取出 *config.FieldError）
+func (c *Config) Validate() error {
+	errs := make([]error, 0)
+	check := func(ok bool, field string, value any, reason string) {
+		if !ok {
+			errs = append(errs, &config.FieldError{Field: field, Value: value, Reason: reason})
+		}
+	}
+	check(c.TxnsPerBlock >= 0, "TxnsPerBlock", c.TxnsPerBlock, "must be >= 0")
+	check(c.Accounts >= 1, "Accounts", c.Accounts, "must be >= 1")
+	// rand.NewZipf 在 s <= 1 或 v < 1 时返回 nil
+	check(c.ZipfS > 1, "ZipfS", c.ZipfS, "must be > 1")
+	check(c.ZipfV >= 1, "ZipfV", c.ZipfV, "must be >= 1")
+	check(c.HotAccounts >= 0, "HotAccounts", c.HotAccounts, "must be >= 0")
+	for _, p := range []struct {
+		name  string
+		value float64
+	}{
+		{"HotFraction", c.HotFraction},
+		{"NewAccount", c.NewAccount},
+		{"BurstProb", c.BurstProb},
+		{"BurstAccount", c.BurstAccount},
+	} {
+		check(p.value >= 0 && p.value <= 1, p.name, p.value, "must be in [0, 1]")
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// 接收区块的对象，CSCForest 与 oracle.Builder 都满足该接口
+type Sink interface {
+	AddwithBlock(blockNumber int, txnStrings []string)
+}
+
+// 按区块生成类似真实链上数据的交易
+type Generator struct {
+	Config     *Config
+	r          *rand.Rand
+	zipf       *rand.Zipf
+	nextBlock  int
+	newAccount int // 下一个新账户的编号
+}
+
+// cfg 为 nil 时使用 DefaultConfig，参数不合法时返回错误
+func NewGenerator(cfg *Config) (*Generator, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	r := rand.New(rand.NewSource(cfg.Seed))
+	return &Generator{
+		Config:     cfg,
+		r:          r,
+		zipf:       rand.NewZipf(r, cfg.ZipfS, cfg.ZipfV, uint64(cfg.Accounts-1)),
+		nextBlock:  cfg.StartBlock,
+		newAccount: cfg.HotAccounts + cfg.Accounts,
+	}, nil
+}
+
+// 热点账户、普通账户与新账户使用不同的编号区间
+func account(id int) string {
+	return fmt.Sprintf("0x%040x", id)
+}
+
+func (g *Generator) hotAccount() string {
+	return account(g.r.Intn(g.Config.HotAccounts))
+}
+
+// 排名越靠前的普通账户越活跃
+func (g *Generator) zipfAccount() string {
+	return account(g.Config.HotAccounts + int(g.zipf.Uint64()))
+}
+
+func (g *Generator) freshAccount() string {
+	id := g.newAccount
+	g.newAccount++
+	return account(id)
+}
+
+func (g *Generator) isHot() bool {
+	return g.Config.HotAccounts > 0 && g.r.Float64() < g.Config.HotFraction
+}
+
+// 生成下一个区块，返回区块号以及 "hash,blockNumber,sender,receiver" 格式的交易
+func (g *Generator) NextBlock() (int, []string) {
+	blockNumber := g.nextBlock
+	g.nextBlock++
+	newAccount := g.Config.NewAccount
+	if g.r.Float64() < g.Config.BurstProb {
+		newAccount = g.Config.BurstAccount
+	}
+	txns := make([]string, 0, g.Config.TxnsPerBlock)
+	for i := 0; i < g.Config.TxnsPerBlock; i++ {
+		var sender, receiver string
+		switch {
+		case g.r.Float64() < newAccount:
+			sender = g.freshAccount()
+		case g.isHot():
+			// 交易所提现
+			sender = g.hotAccount()
+		default:
+			sender = g.zipfAccount()
+		}
+		if g.isHot() {
+			// 向交易所充值
+			receiver = g.hotAccount()
+		} else {
+			receiver = g.zipfAccount()
+		}
+		hash := fmt.Sprintf("0x%016x%016x", g.r.Uint64(), g.r.Uint64())
+		txns = append(txns, fmt.Sprintf("%s,%d,%s,%s", hash, blockNumber, sender, receiver))
+	}
+	return blockNumber, txns
+}
+
+// 生成 blockNum 个区块并依次写入 sink
+func (g *Generator) Feed(sink Sink, blockNum int) {
+	for i := 0; i < blockNum; i++ {
+		sink.AddwithBlock(g.NextBlock())
+	}
+}
@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/context"
+	"github.com/liuys-dase/csc-tree/csctree"
+	"github.com/liuys-dase/csc-tree/oracle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorDeterministic(t *testing.T) {
+	g1, err := NewGenerator(nil)
+	assert.Nil(t, err)
+	g2, _ := NewGenerator(nil)
+	for i := 0; i < 20; i++ {
+		b1, txns1 := g1.NextBlock()
+		b2, txns2 := g2.NextBlock()
+		assert.Equal(t, i, b1)
+		assert.Equal(t, b1, b2)
+		assert.Equal(t, txns1, txns2)
+		assert.Equal(t, DefaultConfig().TxnsPerBlock, len(txns1))
+		for _, txn := range txns1 {
+			tx := block.NewTrasactionFromString(txn)
+			assert.Equal(t, b1, tx.BlockNumber.Start)
+			assert.Len(t, tx.Sender, 42)
+			assert.Len(t, tx.Receiver, 42)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.Seed = 2
+	g1, _ = NewGenerator(nil)
+	g2, _ = NewGenerator(cfg)
+	_, txns1 := g1.NextBlock()
+	_, txns2 := g2.NextBlock()
+	assert.NotEqual(t, txns1, txns2)
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, DefaultConfig().Validate())
+	// rand.NewZipf 对这些参数返回 nil，必须在构建时报错而不是在 NextBlock 中 panic
+	for _, set := range []func(c *Config){
+		func(c *Config) { c.ZipfS = 1 },
+		func(c *Config) { c.ZipfS = 0.5 },
+		func(c *Config) { c.ZipfV = 0.5 },
+		func(c *Config) { c.Accounts = 0 },
+		func(c *Config) { c.TxnsPerBlock = -1 },
+		func(c *Config) { c.HotFraction = 1.5 },
+	} {
+		cfg := DefaultConfig()
+		set(cfg)
+		g, err := NewGenerator(cfg)
+		assert.Nil(t, g)
+		var fe *config.FieldError
+		assert.True(t, errors.As(err, &fe))
+	}
+	cfg := DefaultConfig()
+	cfg.ZipfS = 1
+	cfg.ZipfV = 0
+	err := cfg.Validate()
+	assert.Contains(t, err.Error(), "ZipfS")
+	assert.Contains(t, err.Error(), "ZipfV")
+}
+
+func TestGeneratorDistribution(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BurstProb = 0
+	g, _ := NewGenerator(cfg)
+	counts := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		_, txns := g.NextBlock()
+		for _, txn := range txns {
+			counts[block.NewTrasactionFromString(txn).Sender]++
+		}
+	}
+	// 热点账户远比普通账户活跃
+	assert.True(t, counts[account(0)] > counts[account(cfg.HotAccounts+100)])
+	// 有新账户出现
+	assert.True(t, counts[account(cfg.HotAccounts+cfg.Accounts)] == 1)
+}
+
+func TestFeed(t *testing.T) {
+	ctx := &context.Context{
+		Config: &config.ServerConfig{
+			CSCTreeConfig: &config.CSCTreeConfig{
+				MaxLevel:            4,
+				BfFalsePositiveRate: 0.01,
+				BfHashFuncNum:       7,
+				FingerprintSize:     8,
+				FingerprintNum:      4,
+				MaxKickAttempts:     30,
+				PartitionNum:        16,
+				RepetitionNum:       3,
+				MaxElementNumPerPar: 5,
+				SketchLevel:         0,
+				UseNodeIndex:        true,
+				LeafNum:             4,
+			},
+		},
+	}
+	cfg := DefaultConfig()
+	cfg.TxnsPerBlock = 20
+	b := oracle.NewBuilder(csctree.NewCSCForest(ctx))
+	g, _ := NewGenerator(cfg)
+	g.Feed(b, 32)
+	assert.Equal(t, 32, b.Oracle.BlockNum)
+	report := oracle.Evaluate(b.Forest, b.Oracle, &oracle.EvalOptions{SampleSize: 50})
+	assert.Equal(t, 0, report.FalseNegatives)
+}
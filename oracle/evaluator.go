@@ -0,0 +1,164 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/csctree"
+)
+
+type EvalOptions struct {
+	SampleSize int   // 抽样的账户数量，<= 0 或大于账户总数时枚举所有账户
+	Seed       int64 // 抽样使用的随机种子
+}
+
+// 单棵 CSCTree 的准确率
+type TreeAccuracy struct {
+	Tree              int               `json:"tree"`
+	Range             *block.BlockRange `json:"range"`
+	Sealed            bool              `json:"sealed"`
+	BitSize           int               `json:"bit_size"` // GetBitSize 的结果（字节）
+	TruePositives     int               `json:"true_positives"`
+	FalsePositives    int               `json:"false_positives"`
+	FalseNegatives    int               `json:"false_negatives"`
+	Negatives         int               `json:"negatives"` // 查询账户在该 CSCTree 范围内未出现的区块数之和
+	Precision         float64           `json:"precision"`
+	FalsePositiveRate float64           `json:"false_positive_rate"`
+}
+
+func (ta *TreeAccuracy) update() {
+	ta.Precision = precision(ta.TruePositives, ta.FalsePositives)
+	ta.FalsePositiveRate = rate(ta.FalsePositives, ta.Negatives)
+}
+
+// 整个 CSCForest 的准确率以及空间开销
+type Report struct {
+	Config            *config.CSCTreeConfig `json:"config"`
+	Accounts          int                   `json:"accounts"` // 参与评估的账户数量
+	TruePositives     int                   `json:"true_positives"`
+	FalsePositives    int                   `json:"false_positives"`
+	FalseNegatives    int                   `json:"false_negatives"` // 必须为 0
+	Negatives         int                   `json:"negatives"`
+	Precision         float64               `json:"precision"`
+	FalsePositiveRate float64               `json:"false_positive_rate"`
+	BitSize           int                   `json:"bit_size"`       // 所有 CSCTree 的 GetBitSize 之和（字节）
+	Entries           int                   `json:"entries"`        // Oracle 中 (账户, 区块) 对的数量
+	BitsPerEntry      float64               `json:"bits_per_entry"` // 每个 (账户, 区块) 对平均占用的位数
+	Trees             []*TreeAccuracy       `json:"trees"`
+}
+
+// 逐棵 CSCTree 查询账户，并与 Oracle 的结果比较
+func Evaluate(forest *csctree.CSCForest, o *Oracle, opts *EvalOptions) *Report {
+	if opts == nil {
+		opts = &EvalOptions{}
+	}
+	accounts := o.Sample(opts.SampleSize, opts.Seed)
+	cfg := forest.Context.Config.CSCTreeConfig
+	report := &Report{
+		Config:   cfg.Redacted(),
+		Accounts: len(accounts),
+		Entries:  o.EntryNum(),
+		Trees:    make([]*TreeAccuracy, 0),
+	}
+	for i, t := range forest.CSCForest {
+		if t.IsEmpty() {
+			continue
+		}
+		_, sealed := t.Root.(*csctree.RootNode)
+		ta := &TreeAccuracy{
+			Tree:    i,
+			Range:   t.Root.GetRange(),
+			Sealed:  sealed,
+			BitSize: t.GetBitSize(),
+		}
+		for _, account := range accounts {
+			var nodes []csctree.Node
+			if !cfg.UseFlatten {
+				nodes, _ = t.Get(account)
+			} else {
+				nodes, _ = t.GetWithKLeafs(account)
+			}
+			truth := o.GetWithRange(account, ta.Range.Start, ta.Range.End)
+			compare(ta, nodes, truth)
+		}
+		ta.update()
+		report.add(ta)
+	}
+	report.Precision = precision(report.TruePositives, report.FalsePositives)
+	report.FalsePositiveRate = rate(report.FalsePositives, report.Negatives)
+	report.BitsPerEntry = rate(report.BitSize*8, report.Entries)
+	return report
+}
+
+// 叶子节点对应单个区块，重复返回的区块只统计一次
+func compare(ta *TreeAccuracy, nodes []csctree.Node, truth []int) {
+	expected := make(map[int]bool, len(truth))
+	for _, b := range truth {
+		expected[b] = true
+	}
+	returned := make(map[int]bool, len(nodes))
+	for _, n := range nodes {
+		b := n.GetRange().Start
+		if returned[b] {
+			continue
+		}
+		returned[b] = true
+		if expected[b] {
+			ta.TruePositives++
+		} else {
+			ta.FalsePositives++
+		}
+	}
+	for _, b := range truth {
+		if !returned[b] {
+			ta.FalseNegatives++
+		}
+	}
+	ta.Negatives += ta.Range.Size() - len(truth)
+}
+
+func (r *Report) add(ta *TreeAccuracy) {
+	r.TruePositives += ta.TruePositives
+	r.FalsePositives += ta.FalsePositives
+	r.FalseNegatives += ta.FalseNegatives
+	r.Negatives += ta.Negatives
+	r.BitSize += ta.BitSize
+	r.Trees = append(r.Trees, ta)
+}
+
+func precision(tp int, fp int) float64 {
+	if tp+fp == 0 {
+		return 1
+	}
+	return float64(tp) / float64(tp+fp)
+}
+
+func rate(numerator int, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+func (r *Report) JSON() string {
+	data, _ := json.MarshalIndent(r, "", "  ")
+	return string(data)
+}
+
+// 以表格形式输出每棵 CSCTree 的结果以及汇总
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "accounts: %d, bit size: %d, bits per entry: %.2f\n", r.Accounts, r.BitSize, r.BitsPerEntry)
+	fmt.Fprintf(&sb, "%-6s %-16s %-7s %-10s %-8s %-8s %-8s %-10s %-10s\n",
+		"tree", "range", "sealed", "bit_size", "tp", "fp", "fn", "precision", "fpr")
+	for _, ta := range r.Trees {
+		fmt.Fprintf(&sb, "%-6d %-16s %-7v %-10d %-8d %-8d %-8d %-10.4f %-10.6f\n",
+			ta.Tree, ta.Range.String(), ta.Sealed, ta.BitSize, ta.TruePositives, ta.FalsePositives, ta.FalseNegatives, ta.Precision, ta.FalsePositiveRate)
+	}
+	fmt.Fprintf(&sb, "%-6s %-16s %-7s %-10d %-8d %-8d %-8d %-10.4f %-10.6f\n",
+		"total", "", "", r.BitSize, r.TruePositives, r.FalsePositives, r.FalseNegatives, r.Precision, r.FalsePositiveRate)
+	return sb.String()
+}
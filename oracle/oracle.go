@@ -0,0 +1,100 @@
+package oracle
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/csctree"
+)
+
+// 精确的参照索引：账户 -> 有序的区块列表，用于衡量 CSCForest 的误报与漏报
+type Oracle struct {
+	Role     csctree.Role
+	Index    map[string][]int
+	BlockNum int // 已加入的区块数量
+}
+
+func NewOracle(role csctree.Role) *Oracle {
+	return &Oracle{
+		Role:  role,
+		Index: make(map[string][]int),
+	}
+}
+
+// 与 CSCForest.AddwithBlock 使用相同的输入
+func (o *Oracle) AddwithBlock(blockNumber int, txnStrings []string) {
+	as := block.NewAccountSetFromBlock(blockNumber, txnStrings, o.Role == csctree.SENDER, blockNumber)
+	for account := range as.GetAccount() {
+		// 乱序加入时也保持有序
+		blocks := o.Index[account]
+		i := sort.SearchInts(blocks, blockNumber)
+		if i < len(blocks) && blocks[i] == blockNumber {
+			continue
+		}
+		o.Index[account] = slices.Insert(blocks, i, blockNumber)
+	}
+	o.BlockNum++
+}
+
+// 返回账户出现过的所有区块
+func (o *Oracle) Get(account string) []int {
+	return o.Index[account]
+}
+
+// 返回账户在 [start_block, end_block] 中出现过的区块
+func (o *Oracle) GetWithRange(account string, start_block int, end_block int) []int {
+	blocks := o.Index[account]
+	i := sort.SearchInts(blocks, start_block)
+	j := sort.SearchInts(blocks, end_block+1)
+	return blocks[i:j]
+}
+
+// 按字典序返回所有账户
+func (o *Oracle) Accounts() []string {
+	accounts := make([]string, 0, len(o.Index))
+	for account := range o.Index {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// 使用 seed 随机抽取 n 个账户，n <= 0 或大于账户总数时返回所有账户
+func (o *Oracle) Sample(n int, seed int64) []string {
+	accounts := o.Accounts()
+	if n <= 0 || n >= len(accounts) {
+		return accounts
+	}
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(accounts), func(i, j int) { accounts[i], accounts[j] = accounts[j], accounts[i] })
+	return accounts[:n]
+}
+
+// 所有 (账户, 区块) 对的数量
+func (o *Oracle) EntryNum() int {
+	total := 0
+	for _, blocks := range o.Index {
+		total += len(blocks)
+	}
+	return total
+}
+
+// 同时写入 CSCForest 与 Oracle，保证两者使用完全相同的数据
+type Builder struct {
+	Forest *csctree.CSCForest
+	Oracle *Oracle
+}
+
+func NewBuilder(forest *csctree.CSCForest) *Builder {
+	return &Builder{
+		Forest: forest,
+		Oracle: NewOracle(forest.Role),
+	}
+}
+
+func (b *Builder) AddwithBlock(blockNumber int, txnStrings []string) {
+	b.Forest.AddwithBlock(blockNumber, txnStrings)
+	b.Oracle.AddwithBlock(blockNumber, txnStrings)
+}
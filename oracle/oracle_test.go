@@ -0,0 +1,82 @@
+package oracle
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/context"
+	"github.com/liuys-dase/csc-tree/csctree"
+	"github.com/stretchr/testify/assert"
+)
+
+func testContext(useFlatten bool) *context.Context {
+	return &context.Context{
+		Config: &config.ServerConfig{
+			CSCTreeConfig: &config.CSCTreeConfig{
+				MaxLevel:            5,
+				BfFalsePositiveRate: 0.01,
+				BfHashFuncNum:       7,
+				FingerprintSize:     8,
+				FingerprintNum:      4,
+				MaxKickAttempts:     30,
+				PartitionNum:        16,
+				RepetitionNum:       3,
+				MaxElementNumPerPar: 5,
+				SketchLevel:         0,
+				UseNodeIndex:        true,
+				LeafNum:             4,
+				UseFlatten:          useFlatten,
+			},
+		},
+	}
+}
+
+func buildTestIndex(ctx *context.Context, blockNum int) *Builder {
+	r := rand.New(rand.NewSource(1))
+	b := NewBuilder(csctree.NewCSCForest(ctx))
+	for n := 0; n < blockNum; n++ {
+		txns := make([]string, 0)
+		for i := 0; i < 12; i++ {
+			sender := fmt.Sprintf("0x%04d", int(r.ExpFloat64()*8))
+			txns = append(txns, fmt.Sprintf("tx%d_%d,%d,%s,0xr%d", n, i, n, sender, r.Intn(100)))
+		}
+		b.AddwithBlock(n, txns)
+	}
+	return b
+}
+
+func TestOracle(t *testing.T) {
+	o := NewOracle(csctree.SENDER)
+	o.AddwithBlock(3, []string{"h1,3,0xa,0xb", "h2,3,0xa,0xc"})
+	o.AddwithBlock(1, []string{"h3,1,0xa,0xb"})
+	o.AddwithBlock(5, []string{"h4,5,0xd,0xa"})
+	assert.Equal(t, []int{1, 3}, o.Get("0xa"))
+	assert.Equal(t, []int{3}, o.GetWithRange("0xa", 2, 5))
+	assert.Empty(t, o.GetWithRange("0xa", 4, 5))
+	assert.Equal(t, []string{"0xa", "0xd"}, o.Accounts())
+	assert.Equal(t, 3, o.EntryNum())
+	assert.Equal(t, 3, o.BlockNum)
+}
+
+func TestEvaluate(t *testing.T) {
+	for _, useFlatten := range []bool{false, true} {
+		b := buildTestIndex(testContext(useFlatten), 96)
+		report := Evaluate(b.Forest, b.Oracle, nil)
+		assert.Equal(t, 0, report.FalseNegatives)
+		assert.Equal(t, len(b.Oracle.Accounts()), report.Accounts)
+		assert.Equal(t, b.Oracle.EntryNum(), report.TruePositives)
+		assert.True(t, report.Precision > 0 && report.Precision <= 1)
+		assert.True(t, report.BitsPerEntry > 0)
+		for _, ta := range report.Trees {
+			assert.Equal(t, 0, ta.FalseNegatives)
+		}
+		// 相同的种子抽样结果相同
+		r1 := Evaluate(b.Forest, b.Oracle, &EvalOptions{SampleSize: 5, Seed: 7})
+		r2 := Evaluate(b.Forest, b.Oracle, &EvalOptions{SampleSize: 5, Seed: 7})
+		assert.Equal(t, 5, r1.Accounts)
+		assert.Equal(t, r1.TruePositives, r2.TruePositives)
+		assert.Equal(t, r1.FalsePositives, r2.FalsePositives)
+	}
+}
@@ -0,0 +1,239 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/csctree"
+)
+
+type OpType int
+
+const (
+	LOOKUP     OpType = iota + 1 // 在 CSCForest 中查找单个账户
+	UNION                        // 并集
+	INTERSECT                    // 交集
+	DIFFERENCE                   // 差集，第一个子计划减去其余子计划中确认存在的区块
+)
+
+func (op OpType) String() string {
+	switch op {
+	case LOOKUP:
+		return "LOOKUP"
+	case UNION:
+		return "UNION"
+	case INTERSECT:
+		return "INTERSECT"
+	case DIFFERENCE:
+		return "DIFFERENCE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// 由语法树编译得到的执行计划，连续的 AND / OR 会被展开为多元运算
+type Plan struct {
+	Op       OpType
+	Roles    []csctree.Role // 仅 LOOKUP 使用，多个角色时取并集
+	Account  string         // 仅 LOOKUP 使用
+	Children []*Plan
+}
+
+func (p *Plan) String() string {
+	if p.Op == LOOKUP {
+		roles := make([]string, 0, len(p.Roles))
+		for _, role := range p.Roles {
+			roles = append(roles, role.String())
+		}
+		return fmt.Sprintf("LOOKUP(%s:%s)", strings.Join(roles, "|"), p.Account)
+	}
+	children := make([]string, 0, len(p.Children))
+	for _, c := range p.Children {
+		children = append(children, c.String())
+	}
+	return p.Op.String() + "(" + strings.Join(children, ", ") + ")"
+}
+
+type Evaluator struct {
+	Forests map[csctree.Role]*csctree.CSCForest
+}
+
+// 每个角色对应一个 CSCForest，未提供的角色在查询时会报错
+func NewEvaluator(forests ...*csctree.CSCForest) *Evaluator {
+	e := &Evaluator{
+		Forests: make(map[csctree.Role]*csctree.CSCForest),
+	}
+	for _, f := range forests {
+		e.Forests[f.Role] = f
+	}
+	return e
+}
+
+// 将语法树编译为执行计划
+func (e *Evaluator) Compile(q *Query) (*Plan, error) {
+	return e.compile(q.Root)
+}
+
+func (e *Evaluator) compile(expr Expr) (*Plan, error) {
+	switch n := expr.(type) {
+	case *TermExpr:
+		plan := &Plan{Op: LOOKUP, Account: n.Account}
+		switch n.Role {
+		case "sender":
+			plan.Roles = []csctree.Role{csctree.SENDER}
+		case "receiver":
+			plan.Roles = []csctree.Role{csctree.RECEIVER}
+		default:
+			// account:xxx 在所有已注册的角色中查找
+			for role := range e.Forests {
+				plan.Roles = append(plan.Roles, role)
+			}
+			sort.Slice(plan.Roles, func(i, j int) bool { return plan.Roles[i] < plan.Roles[j] })
+		}
+		for _, role := range plan.Roles {
+			if _, ok := e.Forests[role]; !ok {
+				return nil, fmt.Errorf("no %v index available for term %v", role, n)
+			}
+		}
+		if len(plan.Roles) == 0 {
+			return nil, fmt.Errorf("no index available for term %v", n)
+		}
+		return plan, nil
+	case *OrExpr:
+		return e.compileNary(UNION, n.Left, n.Right)
+	case *AndExpr:
+		return e.compileNary(INTERSECT, n.Left, n.Right)
+	case *DiffExpr:
+		// 左结合：(A AND NOT B) AND NOT C 展开为 DIFFERENCE(A, B, C)
+		return e.compileNary(DIFFERENCE, n.Left, n.Right)
+	default:
+		return nil, fmt.Errorf("unsupported expression %T", expr)
+	}
+}
+
+func (e *Evaluator) compileNary(op OpType, left Expr, right Expr) (*Plan, error) {
+	l, err := e.compile(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.compile(right)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Op: op}
+	// DIFFERENCE 只展开左侧，右侧作为被减项
+	if l.Op == op {
+		plan.Children = append(plan.Children, l.Children...)
+	} else {
+		plan.Children = append(plan.Children, l)
+	}
+	if r.Op == op && op != DIFFERENCE {
+		plan.Children = append(plan.Children, r.Children...)
+	} else {
+		plan.Children = append(plan.Children, r)
+	}
+	return plan, nil
+}
+
+// 解析并执行查询语句
+func (e *Evaluator) Run(input string) (RangeSet, error) {
+	q, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	return e.Evaluate(q)
+}
+
+// NOT 是尽力而为的：被减项中只由过滤器确认的区块无法减去，仍然保留在结果中。
+// 出现这种区块时 Evaluate 在返回结果的同时返回包装了该错误的 error，结果本身仍然可以使用
+var ErrUncertainDifference = errors.New("NOT kept blocks the subtracted term may contain")
+
+// 执行查询语句。DIFFERENCE 保留了被减项可能包含的区块时，返回结果以及包装了 ErrUncertainDifference 的错误
+func (e *Evaluator) Evaluate(q *Query) (RangeSet, error) {
+	plan, err := e.Compile(q)
+	if err != nil {
+		return nil, err
+	}
+	_, res, kept := e.execute(plan, q.Range)
+	if !kept.IsEmpty() {
+		return res, fmt.Errorf("%w: %d blocks in %v", ErrUncertainDifference, kept.BlockCount(), kept)
+	}
+	return res, nil
+}
+
+// 在 blockRange 内执行计划，blockRange 为 nil 表示不限制范围。
+// 结果可能误报但不会漏报：DIFFERENCE 只减去被减项中由 HashMap CSCR 确认存在的区块，
+// 因此被减项的误报不会导致结果漏报，代价是被减项只由过滤器确认的区块仍然保留在结果中。
+// SketchLevel 为 0 时不存在 HashMap CSCR，NOT 不会减去任何区块；需要知道是否有这种区块时使用 Evaluate
+func (e *Evaluator) Execute(plan *Plan, blockRange *block.BlockRange) RangeSet {
+	_, res, _ := e.execute(plan, blockRange)
+	return res
+}
+
+// 在 blockRange 内执行计划，返回确认满足计划的区块 exact、可能满足计划的区块 possible（exact 是 possible 的子集），
+// 以及 possible 中因为 DIFFERENCE 的被减项可能包含但无法确认而保留下来的区块 kept
+func (e *Evaluator) execute(plan *Plan, blockRange *block.BlockRange) (exact RangeSet, possible RangeSet, kept RangeSet) {
+	switch plan.Op {
+	case LOOKUP:
+		exact, possible = e.lookup(plan, blockRange)
+		return exact, possible, RangeSet{}
+	case UNION:
+		exact, possible, kept = RangeSet{}, RangeSet{}, RangeSet{}
+		for _, c := range plan.Children {
+			ce, cp, ck := e.execute(c, blockRange)
+			exact, possible, kept = exact.Union(ce), possible.Union(cp), kept.Union(ck)
+		}
+		return exact, possible, kept
+	case INTERSECT:
+		exact, possible, kept = e.execute(plan.Children[0], blockRange)
+		for _, c := range plan.Children[1:] {
+			if possible.IsEmpty() {
+				break
+			}
+			// 后续子计划只需要在已有结果覆盖的范围内查询，GetWithRange 会据此剪枝
+			ce, cp, ck := e.execute(c, possible.Hull())
+			exact, possible, kept = exact.Intersect(ce), possible.Intersect(cp), kept.Union(ck)
+		}
+		return exact, possible, kept.Intersect(possible)
+	case DIFFERENCE:
+		exact, possible, kept = e.execute(plan.Children[0], blockRange)
+		for _, c := range plan.Children[1:] {
+			if possible.IsEmpty() {
+				break
+			}
+			// 确认存在的区块减去可能存在的区块，可能存在的区块只减去确认存在的区块
+			ce, cp, _ := e.execute(c, possible.Hull())
+			kept = kept.Union(possible.Intersect(cp.Difference(ce)))
+			exact, possible = exact.Difference(cp), possible.Difference(ce)
+		}
+		return exact, possible, kept.Intersect(possible)
+	default:
+		return RangeSet{}, RangeSet{}, RangeSet{}
+	}
+}
+
+// 查找计划中的账户，返回由 HashMap CSCR 确认的区块以及所有返回的区块，其余来源都可能误报
+func (e *Evaluator) lookup(plan *Plan, blockRange *block.BlockRange) (RangeSet, RangeSet) {
+	exact, possible := RangeSet{}, RangeSet{}
+	for _, role := range plan.Roles {
+		forest := e.Forests[role]
+		var nodes []csctree.Node
+		if blockRange == nil {
+			nodes, _ = forest.Get(plan.Account)
+		} else {
+			nodes, _ = forest.GetWithRange(plan.Account, blockRange.Start, blockRange.End)
+		}
+		exactNodes := make([]csctree.Node, 0, len(nodes))
+		for _, n := range nodes {
+			if source, _, _ := csctree.Provenance(n); source == csctree.FROM_HASHMAP_CSCR {
+				exactNodes = append(exactNodes, n)
+			}
+		}
+		exact = exact.Union(NewRangeSetFromNodes(exactNodes).Clip(blockRange))
+		possible = possible.Union(NewRangeSetFromNodes(nodes).Clip(blockRange))
+	}
+	return exact, possible
+}
@@ -0,0 +1,315 @@
+package query
+
+/*
+
+	查询语法：
+		query   := expr [ BLOCKS NUMBER .. NUMBER ]
+		expr    := andExpr { OR andExpr }
+		andExpr := primary { AND [NOT] primary }
+		primary := ROLE:ACCOUNT | ( expr )
+
+	ROLE 可以是 sender、receiver 或 account（任意角色），关键字不区分大小写，例如：
+		sender:0xabc OR (receiver:0xdef AND sender:0x123) BLOCKS 18000000..18100000
+	`A AND NOT B` 表示差集，NOT 只能出现在 AND 之后；NOT 只减去确认存在的区块，是尽力而为的（见 ErrUncertainDifference）
+
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/liuys-dase/csc-tree/block"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokTerm
+	tokNumber
+	tokAnd
+	tokOr
+	tokNot
+	tokBlocks
+	tokDotDot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	Type  tokenType
+	Value string
+	Pos   int
+}
+
+func (t token) String() string {
+	if t.Type == tokEOF {
+		return "end of query"
+	}
+	return strconv.Quote(t.Value)
+}
+
+// 语法错误，Pos 为出错位置在原始查询串中的字节偏移
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query syntax error at offset %d: %s", e.Pos, e.Msg)
+}
+
+func isTermChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == ':' || r == '_' || r == '-'
+}
+
+func tokenize(input string) ([]token, error) {
+	tokens := make([]token, 0)
+	i := 0
+	for i < len(input) {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, token{Type: tokLParen, Value: "(", Pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{Type: tokRParen, Value: ")", Pos: i})
+			i++
+		case r == '.':
+			if i+1 >= len(input) || input[i+1] != '.' {
+				return nil, &SyntaxError{Pos: i, Msg: "expected \"..\""}
+			}
+			tokens = append(tokens, token{Type: tokDotDot, Value: "..", Pos: i})
+			i += 2
+		case isTermChar(r):
+			start := i
+			for i < len(input) {
+				r, size := utf8.DecodeRuneInString(input[i:])
+				if !isTermChar(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, classify(input[start:i], start))
+		default:
+			return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	tokens = append(tokens, token{Type: tokEOF, Pos: len(input)})
+	return tokens, nil
+}
+
+func classify(word string, pos int) token {
+	switch strings.ToUpper(word) {
+	case "AND":
+		return token{Type: tokAnd, Value: word, Pos: pos}
+	case "OR":
+		return token{Type: tokOr, Value: word, Pos: pos}
+	case "NOT":
+		return token{Type: tokNot, Value: word, Pos: pos}
+	case "BLOCKS":
+		return token{Type: tokBlocks, Value: word, Pos: pos}
+	}
+	if _, err := strconv.Atoi(word); err == nil {
+		return token{Type: tokNumber, Value: word, Pos: pos}
+	}
+	return token{Type: tokTerm, Value: word, Pos: pos}
+}
+
+// 语法树中的节点
+type Expr interface {
+	String() string
+}
+
+// 单个账户查询，Role 为 account 时表示任意角色
+type TermExpr struct {
+	Role    string
+	Account string
+}
+
+func (e *TermExpr) String() string {
+	return e.Role + ":" + e.Account
+}
+
+type AndExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (e *AndExpr) String() string {
+	return "(" + e.Left.String() + " AND " + e.Right.String() + ")"
+}
+
+type OrExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (e *OrExpr) String() string {
+	return "(" + e.Left.String() + " OR " + e.Right.String() + ")"
+}
+
+// Left AND NOT Right
+type DiffExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (e *DiffExpr) String() string {
+	return "(" + e.Left.String() + " AND NOT " + e.Right.String() + ")"
+}
+
+// 解析后的查询，Range 为 nil 表示查询全部区块
+type Query struct {
+	Root  Expr
+	Range *block.BlockRange
+}
+
+func (q *Query) String() string {
+	if q.Range == nil {
+		return q.Root.String()
+	}
+	return fmt.Sprintf("%v BLOCKS %d..%d", q.Root, q.Range.Start, q.Range.End)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// 解析查询语句
+func Parse(input string) (*Query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{Root: root}
+	if p.peek().Type == tokBlocks {
+		p.next()
+		if q.Range, err = p.parseRange(); err != nil {
+			return nil, err
+		}
+	}
+	if tok := p.peek(); tok.Type != tokEOF {
+		return nil, &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("unexpected %v", tok)}
+	}
+	return q, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.Type != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().Type == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &OrExpr{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().Type == tokAnd {
+		p.next()
+		negate := false
+		if p.peek().Type == tokNot {
+			p.next()
+			negate = true
+		}
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if negate {
+			left = &DiffExpr{Left: left, Right: right}
+		} else {
+			left = &AndExpr{Left: left, Right: right}
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.next()
+	switch tok.Type {
+	case tokLParen:
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.Type != tokRParen {
+			return nil, &SyntaxError{Pos: closing.Pos, Msg: fmt.Sprintf("expected \")\", got %v", closing)}
+		}
+		return e, nil
+	case tokTerm:
+		return parseTerm(tok)
+	case tokNot:
+		return nil, &SyntaxError{Pos: tok.Pos, Msg: "NOT is only allowed after AND"}
+	default:
+		return nil, &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("expected account term or \"(\", got %v", tok)}
+	}
+}
+
+func parseTerm(tok token) (Expr, error) {
+	role, account, ok := strings.Cut(tok.Value, ":")
+	if !ok || account == "" {
+		return nil, &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("expected role:account, got %v", tok)}
+	}
+	role = strings.ToLower(role)
+	switch role {
+	case "sender", "receiver", "account":
+	default:
+		return nil, &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("unknown role %q", role)}
+	}
+	return &TermExpr{Role: role, Account: account}, nil
+}
+
+func (p *parser) parseRange() (*block.BlockRange, error) {
+	startTok := p.next()
+	if startTok.Type != tokNumber {
+		return nil, &SyntaxError{Pos: startTok.Pos, Msg: fmt.Sprintf("expected start block, got %v", startTok)}
+	}
+	if dots := p.next(); dots.Type != tokDotDot {
+		return nil, &SyntaxError{Pos: dots.Pos, Msg: fmt.Sprintf("expected \"..\", got %v", dots)}
+	}
+	endTok := p.next()
+	if endTok.Type != tokNumber {
+		return nil, &SyntaxError{Pos: endTok.Pos, Msg: fmt.Sprintf("expected end block, got %v", endTok)}
+	}
+	start, _ := strconv.Atoi(startTok.Value)
+	end, _ := strconv.Atoi(endTok.Value)
+	if start > end {
+		return nil, &SyntaxError{Pos: startTok.Pos, Msg: fmt.Sprintf("empty block range %d..%d", start, end)}
+	}
+	return block.NewBlockRange(start, end), nil
+}
@@ -0,0 +1,225 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/context"
+	"github.com/liuys-dase/csc-tree/csctree"
+	"github.com/liuys-dase/csc-tree/generator"
+	"github.com/liuys-dase/csc-tree/oracle"
+	"github.com/stretchr/testify/assert"
+)
+
+func queryContext() *context.Context {
+	return &context.Context{
+		Config: &config.ServerConfig{
+			CSCTreeConfig: &config.CSCTreeConfig{
+				MaxLevel:            4,
+				BfFalsePositiveRate: 0.01,
+				BfHashFuncNum:       7,
+				FingerprintSize:     16,
+				FingerprintNum:      4,
+				MaxKickAttempts:     30,
+				PartitionNum:        16,
+				RepetitionNum:       3,
+				MaxElementNumPerPar: 5,
+				SketchLevel:         0,
+				UseNodeIndex:        true,
+				LeafNum:             4,
+				UseFlatten:          false,
+			},
+		},
+	}
+}
+
+// 构造 32 个区块：0xa 在偶数区块发送，0xb 在 3 的倍数区块发送，0xc 在 [8,15] 中接收
+func buildForests(ctx *context.Context) (*csctree.CSCForest, *csctree.CSCForest) {
+	senders := csctree.NewCSCForestWithRole(ctx, csctree.SENDER)
+	receivers := csctree.NewCSCForestWithRole(ctx, csctree.RECEIVER)
+	for b := 0; b < 32; b++ {
+		txns := []string{fmt.Sprintf("h%d,%d,0xfill%d,0xsink%d", b, b, b, b)}
+		if b%2 == 0 {
+			txns = append(txns, fmt.Sprintf("a%d,%d,0xa,0xz%d", b, b, b))
+		}
+		if b%3 == 0 {
+			txns = append(txns, fmt.Sprintf("b%d,%d,0xb,0xz%d", b, b, b))
+		}
+		if b >= 8 && b <= 15 {
+			txns = append(txns, fmt.Sprintf("c%d,%d,0xy%d,0xc", b, b, b))
+		}
+		senders.AddwithBlock(b, txns)
+		receivers.AddwithBlock(b, txns)
+	}
+	return senders, receivers
+}
+
+func blocksOf(pred func(int) bool) RangeSet {
+	ranges := make([]*block.BlockRange, 0)
+	for b := 0; b < 32; b++ {
+		if pred(b) {
+			ranges = append(ranges, block.NewBlockRange(b, b))
+		}
+	}
+	return NewRangeSet(ranges)
+}
+
+func assertSubset(t *testing.T, expected RangeSet, actual RangeSet) {
+	assert.True(t, expected.Difference(actual).IsEmpty(), "missing blocks %v in %v", expected.Difference(actual), actual)
+}
+
+func TestParse(t *testing.T) {
+	q, err := Parse("sender:0xabc OR (receiver:0xdef AND sender:0x123) BLOCKS 18000000..18100000")
+	assert.Nil(t, err)
+	assert.Equal(t, "(sender:0xabc OR (receiver:0xdef AND sender:0x123)) BLOCKS 18000000..18100000", q.String())
+
+	q, err = Parse("sender:0xa and not receiver:0xb or account:0xc")
+	assert.Nil(t, err)
+	assert.Equal(t, "((sender:0xa AND NOT receiver:0xb) OR account:0xc)", q.String())
+	assert.Nil(t, q.Range)
+}
+
+func TestParseError(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"sender:",
+		"owner:0xa",
+		"NOT sender:0xa",
+		"(sender:0xa",
+		"sender:0xa OR",
+		"sender:0xa BLOCKS 10",
+		"sender:0xa BLOCKS 10..5",
+		"sender:0xa . 5",
+		"sender:0xa $",
+	} {
+		_, err := Parse(input)
+		assert.NotNil(t, err, input)
+	}
+	// 出错位置为字节偏移，非 ASCII 的账户名之后仍然可以用来切分原始查询串
+	input := "sender:账户 $"
+	_, err := Parse(input)
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, "$", input[syntaxErr.Pos:])
+}
+
+func TestRangeSet(t *testing.T) {
+	s := NewRangeSet([]*block.BlockRange{
+		block.NewBlockRange(5, 6), block.NewBlockRange(1, 2), block.NewBlockRange(3, 3), block.NewBlockRange(10, 12),
+	})
+	assert.Equal(t, "[[1,3] [5,6] [10,12]]", fmt.Sprint(s))
+	s2 := NewRangeSet([]*block.BlockRange{block.NewBlockRange(4, 11)})
+	assert.Equal(t, "[[5,6] [10,11]]", fmt.Sprint(s.Intersect(s2)))
+	assert.Equal(t, "[[1,12]]", fmt.Sprint(s.Union(s2)))
+	assert.Equal(t, "[[1,3] [12,12]]", fmt.Sprint(s.Difference(s2)))
+	assert.Equal(t, "[1,12]", s.Hull().String())
+	assert.Equal(t, 8, s.BlockCount())
+	assert.True(t, s.Contains(11))
+	assert.False(t, s.Contains(8))
+}
+
+func TestCompile(t *testing.T) {
+	senders, receivers := buildForests(queryContext())
+	e := NewEvaluator(senders, receivers)
+	q, _ := Parse("sender:0xa AND sender:0xb AND receiver:0xc OR account:0xd AND NOT sender:0xe AND NOT sender:0xf")
+	plan, err := e.Compile(q)
+	assert.Nil(t, err)
+	assert.Equal(t, "UNION(INTERSECT(LOOKUP(sender:0xa), LOOKUP(sender:0xb), LOOKUP(receiver:0xc)), "+
+		"DIFFERENCE(LOOKUP(sender|receiver:0xd), LOOKUP(sender:0xe), LOOKUP(sender:0xf)))", plan.String())
+
+	_, err = NewEvaluator(senders).Run("receiver:0xc")
+	assert.NotNil(t, err)
+}
+
+func TestEvaluate(t *testing.T) {
+	senders, receivers := buildForests(queryContext())
+	e := NewEvaluator(senders, receivers)
+
+	res, err := e.Run("sender:0xa")
+	assert.Nil(t, err)
+	assert.False(t, res.IsEmpty())
+	assertSubset(t, blocksOf(func(b int) bool { return b%2 == 0 }), res)
+
+	res, _ = e.Run("sender:0xa OR sender:0xb")
+	assertSubset(t, blocksOf(func(b int) bool { return b%2 == 0 || b%3 == 0 }), res)
+
+	res, _ = e.Run("sender:0xa AND sender:0xb")
+	assertSubset(t, blocksOf(func(b int) bool { return b%6 == 0 }), res)
+
+	res, _ = e.Run("(sender:0xa OR sender:0xb) AND receiver:0xc BLOCKS 0..11")
+	assertSubset(t, blocksOf(func(b int) bool { return (b%2 == 0 || b%3 == 0) && b >= 8 && b <= 11 }), res)
+	assert.Equal(t, res, res.Clip(block.NewBlockRange(0, 11)))
+
+	// SketchLevel = MaxLevel 时所有 CSCR 都是精确的 HashMap，被减项不会误报
+	ctx := queryContext()
+	ctx.Config.CSCTreeConfig.SketchLevel = ctx.Config.CSCTreeConfig.MaxLevel
+	e = NewEvaluator(buildForests(ctx))
+	res, err = e.Run("account:0xc AND NOT sender:0xa")
+	assert.Nil(t, err)
+	assertSubset(t, blocksOf(func(b int) bool { return b%2 != 0 && b >= 8 && b <= 15 }), res)
+	for _, r := range res {
+		for b := r.Start; b <= r.End; b++ {
+			assert.NotEqual(t, 0, b%2)
+		}
+	}
+}
+
+// SketchLevel 为 0 时没有 HashMap CSCR，NOT 无法减去任何区块，Evaluate 返回 ErrUncertainDifference
+func TestUncertainDifference(t *testing.T) {
+	e := NewEvaluator(buildForests(queryContext()))
+	a, err := e.Run("sender:0xa")
+	assert.Nil(t, err)
+	res, err := e.Run("sender:0xa AND NOT sender:0xa")
+	assert.ErrorIs(t, err, ErrUncertainDifference)
+	assert.Equal(t, a, res)
+	// 被减项与结果没有重合时不需要减去任何区块
+	_, err = e.Run("sender:0xa AND NOT sender:0xmissing")
+	assert.Nil(t, err)
+}
+
+// 被减项的 BloomFilter 误报较多时 DIFFERENCE 仍然不会漏报
+func TestDifferenceNoFalseNegatives(t *testing.T) {
+	ctx := queryContext()
+	ctx.Config.CSCTreeConfig.BfFalsePositiveRate = 0.3
+	ctx.Config.CSCTreeConfig.SketchLevel = 2
+	cfg := generator.DefaultConfig()
+	cfg.Accounts = 200
+	cfg.TxnsPerBlock = 20
+	g, err := generator.NewGenerator(cfg)
+	assert.Nil(t, err)
+	forest := csctree.NewCSCForestWithRole(ctx, csctree.SENDER)
+	o := oracle.NewOracle(csctree.SENDER)
+	for i := 0; i < 64; i++ {
+		b, txns := g.NextBlock()
+		forest.AddwithBlock(b, txns)
+		o.AddwithBlock(b, txns)
+	}
+	toRangeSet := func(blocks []int) RangeSet {
+		ranges := make([]*block.BlockRange, 0, len(blocks))
+		for _, b := range blocks {
+			ranges = append(ranges, block.NewBlockRange(b, b))
+		}
+		return NewRangeSet(ranges)
+	}
+	e := NewEvaluator(forest)
+	accounts := o.Sample(20, 1)
+	for _, a := range accounts {
+		for _, b := range accounts {
+			if a == b {
+				continue
+			}
+			expected := toRangeSet(o.Get(a)).Difference(toRangeSet(o.Get(b)))
+			res, err := e.Run(fmt.Sprintf("sender:%s AND NOT sender:%s", a, b))
+			// 被减项只由过滤器确认的区块会保留在结果中，此时同时返回 ErrUncertainDifference
+			if err != nil {
+				assert.ErrorIs(t, err, ErrUncertainDifference)
+			}
+			assertSubset(t, expected, res)
+			// A - (B - A) = A
+			res, _ = e.Run(fmt.Sprintf("sender:%s AND NOT (sender:%s AND NOT sender:%s)", a, b, a))
+			assertSubset(t, toRangeSet(o.Get(a)), res)
+		}
+	}
+}
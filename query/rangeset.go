@@ -0,0 +1,133 @@
+package query
+
+import (
+	"sort"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/csctree"
+)
+
+// 有序且互不重叠的区块范围集合，相邻的范围会被合并
+type RangeSet []*block.BlockRange
+
+// 将任意顺序、可能重叠的区块范围整理为 RangeSet
+func NewRangeSet(ranges []*block.BlockRange) RangeSet {
+	if len(ranges) == 0 {
+		return RangeSet{}
+	}
+	sorted := make([]*block.BlockRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	res := RangeSet{block.NewBlockRange(sorted[0].Start, sorted[0].End)}
+	for _, r := range sorted[1:] {
+		last := res[len(res)-1]
+		// 重叠或相邻的范围直接合并
+		if r.Start <= last.End+1 {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		res = append(res, block.NewBlockRange(r.Start, r.End))
+	}
+	return res
+}
+
+// 将 CSCForest 返回的节点转换为 RangeSet
+func NewRangeSetFromNodes(nodes []csctree.Node) RangeSet {
+	ranges := make([]*block.BlockRange, 0, len(nodes))
+	for _, n := range nodes {
+		ranges = append(ranges, n.GetRange())
+	}
+	return NewRangeSet(ranges)
+}
+
+func (s RangeSet) IsEmpty() bool {
+	return len(s) == 0
+}
+
+// 集合中包含的区块总数
+func (s RangeSet) BlockCount() int {
+	count := 0
+	for _, r := range s {
+		count += r.Size()
+	}
+	return count
+}
+
+// 包含集合中全部区块的最小范围，集合为空时返回 nil
+func (s RangeSet) Hull() *block.BlockRange {
+	if s.IsEmpty() {
+		return nil
+	}
+	return block.NewBlockRange(s[0].Start, s[len(s)-1].End)
+}
+
+// 判断某个区块是否在集合中
+func (s RangeSet) Contains(blockNumber int) bool {
+	i := sort.Search(len(s), func(i int) bool {
+		return s[i].End >= blockNumber
+	})
+	return i < len(s) && s[i].Start <= blockNumber
+}
+
+func (s RangeSet) Union(s2 RangeSet) RangeSet {
+	ranges := make([]*block.BlockRange, 0, len(s)+len(s2))
+	ranges = append(ranges, s...)
+	ranges = append(ranges, s2...)
+	return NewRangeSet(ranges)
+}
+
+func (s RangeSet) Intersect(s2 RangeSet) RangeSet {
+	res := RangeSet{}
+	i, j := 0, 0
+	for i < len(s) && j < len(s2) {
+		start := max(s[i].Start, s2[j].Start)
+		end := min(s[i].End, s2[j].End)
+		if start <= end {
+			res = append(res, block.NewBlockRange(start, end))
+		}
+		// 结束较早的范围不会再与后续范围相交
+		if s[i].End < s2[j].End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return res
+}
+
+// 返回在 s 中但不在 s2 中的区块
+func (s RangeSet) Difference(s2 RangeSet) RangeSet {
+	res := RangeSet{}
+	j := 0
+	for _, r := range s {
+		start := r.Start
+		// 跳过完全位于当前范围之前的范围
+		for j < len(s2) && s2[j].End < start {
+			j++
+		}
+		k := j
+		for k < len(s2) && s2[k].Start <= r.End {
+			if s2[k].Start > start {
+				res = append(res, block.NewBlockRange(start, s2[k].Start-1))
+			}
+			start = s2[k].End + 1
+			k++
+		}
+		if start <= r.End {
+			res = append(res, block.NewBlockRange(start, r.End))
+		}
+	}
+	return res
+}
+
+// 只保留落在 r 中的区块，r 为 nil 时不做裁剪
+func (s RangeSet) Clip(r *block.BlockRange) RangeSet {
+	if r == nil {
+		return s
+	}
+	return s.Intersect(RangeSet{r})
+}
@@ -0,0 +1,305 @@
+package sweep
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/context"
+	"github.com/liuys-dase/csc-tree/csctree"
+	"github.com/liuys-dase/csc-tree/generator"
+	"github.com/liuys-dase/csc-tree/oracle"
+)
+
+// 需要遍历的参数取值，为空时使用 Base 中的值
+type Grid struct {
+	FingerprintSize []int
+	PartitionNum    []int
+	RepetitionNum   []int
+	SketchLevel     []int
+	LeafNum         []int
+	UseFlatten      []bool
+}
+
+// 按固定顺序展开所有参数组合
+func (g *Grid) Expand(base *config.CSCTreeConfig) []*config.CSCTreeConfig {
+	configs := []*config.CSCTreeConfig{base}
+	expandInt := func(values []int, set func(c *config.CSCTreeConfig, v int)) {
+		if len(values) == 0 {
+			return
+		}
+		next := make([]*config.CSCTreeConfig, 0, len(configs)*len(values))
+		for _, c := range configs {
+			for _, v := range values {
+				cc := *c
+				set(&cc, v)
+				next = append(next, &cc)
+			}
+		}
+		configs = next
+	}
+	expandInt(g.FingerprintSize, func(c *config.CSCTreeConfig, v int) { c.FingerprintSize = v })
+	expandInt(g.PartitionNum, func(c *config.CSCTreeConfig, v int) { c.PartitionNum = v })
+	expandInt(g.RepetitionNum, func(c *config.CSCTreeConfig, v int) { c.RepetitionNum = v })
+	expandInt(g.SketchLevel, func(c *config.CSCTreeConfig, v int) { c.SketchLevel = v })
+	expandInt(g.LeafNum, func(c *config.CSCTreeConfig, v int) { c.LeafNum = v })
+	flatten := make([]int, 0, len(g.UseFlatten))
+	for _, v := range g.UseFlatten {
+		if v {
+			flatten = append(flatten, 1)
+		} else {
+			flatten = append(flatten, 0)
+		}
+	}
+	expandInt(flatten, func(c *config.CSCTreeConfig, v int) { c.UseFlatten = v == 1 })
+	// 避免多个组合共享 Base
+	if len(configs) == 1 {
+		cc := *base
+		configs[0] = &cc
+	}
+	return configs
+}
+
+// 数据来源：依次将区块写入 sink，每次调用都需要产生相同的数据
+type Source func(sink generator.Sink)
+
+// 使用生成器产生 blockNum 个区块，生成器参数不合法时返回错误
+func FromGenerator(cfg *generator.Config, blockNum int) (Source, error) {
+	if _, err := generator.NewGenerator(cfg); err != nil {
+		return nil, err
+	}
+	return func(sink generator.Sink) {
+		g, _ := generator.NewGenerator(cfg)
+		g.Feed(sink, blockNum)
+	}, nil
+}
+
+type Block struct {
+	Number int
+	Txns   []string
+}
+
+// 使用已经加载到内存的数据集
+func FromBlocks(blocks []Block) Source {
+	return func(sink generator.Sink) {
+		for _, b := range blocks {
+			sink.AddwithBlock(b.Number, b.Txns)
+		}
+	}
+}
+
+// 读取每行一条 "hash,blockNumber,sender,receiver" 的数据集，按区块号分组并排序
+func LoadCSV(r io.Reader) ([]Block, error) {
+	groups := make(map[int][]string)
+	scanner := bufio.NewScanner(r)
+	line_num := 0
+	for scanner.Scan() {
+		line_num++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("line %d: expected 4 fields, got %d", line_num, len(fields))
+		}
+		blockNumber, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid block number %q", line_num, fields[1])
+		}
+		groups[blockNumber] = append(groups[blockNumber], line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	blocks := make([]Block, 0, len(groups))
+	for number, txns := range groups {
+		blocks = append(blocks, Block{Number: number, Txns: txns})
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Number < blocks[j].Number })
+	return blocks, nil
+}
+
+type Runner struct {
+	Base       *config.CSCTreeConfig
+	Grid       *Grid
+	Source     Source
+	Role       csctree.Role
+	QueryNum   int   // 查询的账户数量，<= 0 时查询所有账户
+	Seed       int64 // 抽样查询账户的种子
+	RoundNum   int   // 每个账户重复查询的次数，用于测量延迟，<= 0 时为 1
+	OnProgress func(i int, total int, res *Result)
+}
+
+// 一个参数组合的测量结果
+type Result struct {
+	Config            *config.CSCTreeConfig
+	BuildTime         time.Duration
+	BitSize           int    // CSCForest 中所有 CSCTree 的 GetBitSize 之和（字节），不包括 FlattenNode
+	TotalBits         int    // ForestStats 统计的所有 BF 与 CSC 的位数，包括 FlattenNode
+	HeapBytes         uint64 // 构建 CSCForest 前后堆内存的差值
+	P50               time.Duration
+	P90               time.Duration
+	P99               time.Duration
+	FalsePositiveRate float64
+	FalseNegatives    int
+	Precision         float64
+	Err               error // 参数组合不合法时不会运行，其余字段为零值
+}
+
+func (r *Runner) Run() []*Result {
+	configs := r.Grid.Expand(r.Base)
+	results := make([]*Result, 0, len(configs))
+	for i, cfg := range configs {
+		res := r.runOne(cfg)
+		results = append(results, res)
+		if r.OnProgress != nil {
+			r.OnProgress(i, len(configs), res)
+		}
+	}
+	return results
+}
+
+func (r *Runner) runOne(cfg *config.CSCTreeConfig) *Result {
+	role := r.Role
+	if role == 0 {
+		role = csctree.SENDER
+	}
+	// 结果会输出到报告中，不保存 HashKey
+	res := &Result{Config: cfg.Redacted()}
+	ctx, err := context.NewContextWithConfig(cfg)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	// Oracle 单独构建，避免计入 CSCForest 的构建时间与内存
+	o := oracle.NewOracle(role)
+	r.Source(o)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start_time := time.Now()
+	forest := csctree.NewCSCForestWithRole(ctx, role)
+	r.Source(forest)
+	res.BuildTime = time.Since(start_time)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > before.HeapAlloc {
+		res.HeapBytes = after.HeapAlloc - before.HeapAlloc
+	}
+
+	report := oracle.Evaluate(forest, o, &oracle.EvalOptions{SampleSize: r.QueryNum, Seed: r.Seed})
+	res.BitSize = report.BitSize
+	fs := forest.ForestStats()
+	for _, total := range fs.Totals {
+		res.TotalBits += total.Bits
+	}
+	res.FalsePositiveRate = report.FalsePositiveRate
+	res.FalseNegatives = report.FalseNegatives
+	res.Precision = report.Precision
+
+	accounts := o.Sample(r.QueryNum, r.Seed)
+	rounds := max(r.RoundNum, 1)
+	latencies := make([]time.Duration, 0, len(accounts)*rounds)
+	for round := 0; round < rounds; round++ {
+		for _, account := range accounts {
+			query_start := time.Now()
+			forest.Get(account)
+			latencies = append(latencies, time.Since(query_start))
+		}
+	}
+	res.P50 = percentile(latencies, 0.5)
+	res.P90 = percentile(latencies, 0.9)
+	res.P99 = percentile(latencies, 0.99)
+	runtime.KeepAlive(forest)
+	return res
+}
+
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+var resultHeader = []string{
+	"fingerprint_size", "partition_num", "repetition_num", "sketch_level", "leaf_num", "use_flatten",
+	"build_time", "bit_size", "total_bits", "heap_bytes", "p50", "p90", "p99", "fpr", "fn", "error",
+}
+
+func (res *Result) record() []string {
+	c := res.Config
+	return []string{
+		strconv.Itoa(c.FingerprintSize),
+		strconv.Itoa(c.PartitionNum),
+		strconv.Itoa(c.RepetitionNum),
+		strconv.Itoa(c.SketchLevel),
+		strconv.Itoa(c.LeafNum),
+		strconv.FormatBool(c.UseFlatten),
+		res.BuildTime.String(),
+		strconv.Itoa(res.BitSize),
+		strconv.Itoa(res.TotalBits),
+		strconv.FormatUint(res.HeapBytes, 10),
+		res.P50.String(),
+		res.P90.String(),
+		res.P99.String(),
+		strconv.FormatFloat(res.FalsePositiveRate, 'f', 6, 64),
+		strconv.Itoa(res.FalseNegatives),
+		errString(res.Err),
+	}
+}
+
+// 多个错误之间用 "; " 分隔，保证每个结果只占一行
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return strings.ReplaceAll(err.Error(), "\n", "; ")
+}
+
+// 以对齐的表格输出结果
+func Table(results []*Result) string {
+	rows := [][]string{resultHeader}
+	for _, res := range results {
+		rows = append(rows, res.record())
+	}
+	widths := make([]int, len(resultHeader))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	var sb strings.Builder
+	for _, row := range rows {
+		for i, cell := range row {
+			if i > 0 {
+				sb.WriteString("  ")
+			}
+			sb.WriteString(fmt.Sprintf("%-*s", widths[i], cell))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func CSV(results []*Result) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write(resultHeader)
+	for _, res := range results {
+		w.Write(res.record())
+	}
+	w.Flush()
+	return sb.String()
+}
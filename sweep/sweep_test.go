@@ -0,0 +1,120 @@
+package sweep
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/filter/basicfilter"
+	"github.com/liuys-dase/csc-tree/generator"
+	"github.com/stretchr/testify/assert"
+)
+
+func baseConfig() *config.CSCTreeConfig {
+	return &config.CSCTreeConfig{
+		MaxLevel:            4,
+		BfFalsePositiveRate: 0.01,
+		BfHashFuncNum:       7,
+		FingerprintSize:     8,
+		FingerprintNum:      4,
+		MaxKickAttempts:     30,
+		PartitionNum:        16,
+		RepetitionNum:       3,
+		MaxElementNumPerPar: 5,
+		SketchLevel:         0,
+		UseNodeIndex:        true,
+		LeafNum:             4,
+		NodeFilter:          basicfilter.FILTER_BLOOM,
+	}
+}
+
+func TestExpand(t *testing.T) {
+	base := baseConfig()
+	grid := &Grid{FingerprintSize: []int{8, 16}, UseFlatten: []bool{false, true}}
+	configs := grid.Expand(base)
+	assert.Equal(t, 4, len(configs))
+	assert.Equal(t, 8, configs[0].FingerprintSize)
+	assert.False(t, configs[0].UseFlatten)
+	assert.Equal(t, 16, configs[3].FingerprintSize)
+	assert.True(t, configs[3].UseFlatten)
+	// 不修改 Base
+	assert.False(t, base.UseFlatten)
+	assert.Equal(t, 1, len((&Grid{}).Expand(base)))
+}
+
+func TestLoadCSV(t *testing.T) {
+	blocks, err := LoadCSV(strings.NewReader("h1,2,0xa,0xb\nh2,1,0xa,0xc\n\nh3,2,0xd,0xa\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Block{
+		{Number: 1, Txns: []string{"h2,1,0xa,0xc"}},
+		{Number: 2, Txns: []string{"h1,2,0xa,0xb", "h3,2,0xd,0xa"}},
+	}, blocks)
+	_, err = LoadCSV(strings.NewReader("h1,x,0xa,0xb\n"))
+	assert.NotNil(t, err)
+	_, err = LoadCSV(strings.NewReader("h1,1,0xa\n"))
+	assert.NotNil(t, err)
+}
+
+func TestRun(t *testing.T) {
+	cfg := generator.DefaultConfig()
+	cfg.TxnsPerBlock = 20
+	source, err := FromGenerator(cfg, 32)
+	assert.Nil(t, err)
+	base := baseConfig()
+	base.HashKey = "secret"
+	runner := &Runner{
+		Base:     base,
+		Grid:     &Grid{FingerprintSize: []int{8, 16}, UseFlatten: []bool{false, true}},
+		Source:   source,
+		QueryNum: 50,
+	}
+	progress := 0
+	runner.OnProgress = func(i int, total int, res *Result) { progress++ }
+	results := runner.Run()
+	assert.Equal(t, 4, len(results))
+	assert.Equal(t, 4, progress)
+	for _, res := range results {
+		assert.Equal(t, 0, res.FalseNegatives)
+		assert.Equal(t, "REDACTED", res.Config.HashKey)
+		assert.True(t, res.TotalBits >= res.BitSize*8 && res.TotalBits > 0)
+		assert.True(t, res.BuildTime > 0)
+		assert.True(t, res.P50 <= res.P90 && res.P90 <= res.P99)
+	}
+	lines := strings.Split(strings.TrimSpace(Table(results)), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.True(t, strings.HasPrefix(CSV(results), "fingerprint_size,partition_num,"))
+}
+
+// 不合法的参数组合不会构建 CSCForest，错误记录在结果中
+func TestRunInvalidConfig(t *testing.T) {
+	cfg := generator.DefaultConfig()
+	cfg.TxnsPerBlock = 20
+	source, err := FromGenerator(cfg, 8)
+	assert.Nil(t, err)
+	runner := &Runner{
+		Base:     baseConfig(),
+		Grid:     &Grid{FingerprintSize: []int{0, 8}, SketchLevel: []int{0, 100}},
+		Source:   source,
+		QueryNum: 20,
+	}
+	results := runner.Run()
+	assert.Equal(t, 4, len(results))
+	for _, res := range results {
+		valid := res.Config.FingerprintSize == 8 && res.Config.SketchLevel == 0
+		if valid {
+			assert.Nil(t, res.Err)
+			assert.True(t, res.TotalBits > 0)
+			continue
+		}
+		var fe *config.FieldError
+		assert.True(t, errors.As(res.Err, &fe))
+		assert.Equal(t, 0, res.TotalBits)
+	}
+	lines := strings.Split(strings.TrimSpace(CSV(results)), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.Contains(t, lines[1], "invalid FingerprintSize")
+
+	_, err = FromGenerator(&generator.Config{ZipfS: 1}, 8)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,244 @@
+package tuner
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/liuys-dase/csc-tree/block"
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/csctree"
+	"github.com/liuys-dase/csc-tree/filter/basicfilter"
+)
+
+// 数据的特征：每个区块的账户数量，以及每一层合并时兄弟节点之间账户的重合比例
+type Profile struct {
+	AccountsPerBlock float64
+	// Overlap[i] 为第 i+1 层的两个兄弟节点合并时，交集大小与单个节点账户数量的比值，
+	// 超出长度的层使用最后一个值
+	Overlap []float64
+}
+
+func NewProfile(accountsPerBlock float64, overlap float64) *Profile {
+	return &Profile{AccountsPerBlock: accountsPerBlock, Overlap: []float64{overlap}}
+}
+
+func (p *Profile) overlap(level int) float64 {
+	if len(p.Overlap) == 0 {
+		return 0
+	}
+	if level-1 < len(p.Overlap) {
+		return p.Overlap[level-1]
+	}
+	return p.Overlap[len(p.Overlap)-1]
+}
+
+// 使用一组连续区块的样本估计 Profile：按 CSCTree 的方式两两合并，测量每一层的重合比例
+func Calibrate(blocks [][]string, role csctree.Role) (*Profile, error) {
+	if len(blocks) < 2 {
+		return nil, fmt.Errorf("need at least 2 blocks to calibrate, got %d", len(blocks))
+	}
+	sets := make([]map[string]bool, 0, len(blocks))
+	total := 0
+	for i, txns := range blocks {
+		as := block.NewAccountSetFromBlock(i, txns, role == csctree.SENDER, i)
+		set := make(map[string]bool, as.GetSize())
+		for account := range as.GetAccount() {
+			set[account] = true
+		}
+		total += len(set)
+		sets = append(sets, set)
+	}
+	p := &Profile{AccountsPerBlock: float64(total) / float64(len(blocks)), Overlap: make([]float64, 0)}
+	for len(sets) >= 2 {
+		merged := make([]map[string]bool, 0, len(sets)/2)
+		intersection, size := 0, 0
+		for i := 0; i+1 < len(sets); i += 2 {
+			union := make(map[string]bool, len(sets[i])+len(sets[i+1]))
+			for account := range sets[i] {
+				union[account] = true
+				if sets[i+1][account] {
+					intersection++
+				}
+			}
+			for account := range sets[i+1] {
+				union[account] = true
+			}
+			size += len(sets[i]) + len(sets[i+1])
+			merged = append(merged, union)
+		}
+		if size == 0 {
+			p.Overlap = append(p.Overlap, 0)
+		} else {
+			p.Overlap = append(p.Overlap, float64(2*intersection)/float64(size))
+		}
+		sets = merged
+	}
+	return p, nil
+}
+
+// 参数的候选值
+type SearchSpace struct {
+	BfFalsePositiveRate []float64
+	FingerprintSize     []int
+	RepetitionNum       []int
+	PartitionNum        []int
+	SketchLevel         []int
+}
+
+func DefaultSearchSpace() *SearchSpace {
+	return &SearchSpace{
+		BfFalsePositiveRate: []float64{0.1, 0.05, 0.01, 0.005, 0.001},
+		FingerprintSize:     []int{4, 6, 8, 10, 12, 16},
+		RepetitionNum:       []int{1, 2, 3, 4},
+		PartitionNum:        []int{4, 8, 16, 32},
+		SketchLevel:         []int{0, 1, 2, 3},
+	}
+}
+
+// 某个配置下的估计结果
+type Estimate struct {
+	Config         *config.CSCTreeConfig
+	BitSizeBytes   int     // 对应 GetBitSize 的字节数（BF + Sketch CSCR）
+	HashMapEntries int     // HashMap CSCR 中的 (账户, 节点) 对数量
+	Bytes          int     // BitSizeBytes + HashMapEntries * csctree.HashMapEntryBytes
+	BfFpr          float64 // BloomFilter 的误判率
+	CSCRFpr        float64 // 按元素数量加权的 Sketch CSCR 误判率，HashMap 为 0
+	Fpr            float64 // 查询路径上一次 BF 与一次 CSCR 检查的综合误判率
+}
+
+type Budget struct {
+	Bytes    int // 内存预算（字节）
+	BlockNum int // 预计的区块数量
+}
+
+// 基于解析模型在 SearchSpace 中选择满足预算且误判率最低的配置，
+// 模型按非 flatten 模式估计，其余参数（MaxLevel、BfHashFuncNum 等）取自 Base
+type Tuner struct {
+	Base    *config.CSCTreeConfig
+	Profile *Profile
+	Space   *SearchSpace
+}
+
+func NewTuner(base *config.CSCTreeConfig, profile *Profile) *Tuner {
+	return &Tuner{Base: base, Profile: profile, Space: DefaultSearchSpace()}
+}
+
+// 估计一个配置下 blockNum 个区块的内存占用与误判率
+func (t *Tuner) Estimate(cfg *config.CSCTreeConfig, blockNum int) *Estimate {
+	est := &Estimate{Config: cfg, BfFpr: cfg.BfFalsePositiveRate}
+	leafNum := 1 << (cfg.MaxLevel - 1)
+	treeNum := (blockNum + leafNum - 1) / leafNum
+	bfBits := 0
+	cscBits := 0
+	weightedFpr := 0.0
+	sketchElements := 0
+	addCSCR := func(elementNum int, level int, rangeSize int) {
+		if elementNum == 0 {
+			return
+		}
+		// 与 InitializeCSCR 一致：低于 SketchLevel 的 CSCR 使用 HashMap
+		if level-1 < cfg.SketchLevel {
+			est.HashMapEntries += elementNum
+			return
+		}
+		bits, fpr := cscrModel(cfg, elementNum, rangeSize)
+		cscBits += bits / 8
+		weightedFpr += fpr * float64(elementNum)
+		sketchElements += elementNum
+	}
+	// 每一层节点的账户数量
+	size := t.Profile.AccountsPerBlock
+	for level := 1; level < cfg.MaxLevel; level++ {
+		pairs := 1 << (cfg.MaxLevel - 1 - level)
+		intersection := int(math.Round(size * t.Profile.overlap(level)))
+		for i := 0; i < pairs; i++ {
+			bfBits += basicfilter.EstimateM(intersection, cfg.BfFalsePositiveRate, cfg.BfHashFuncNum) / 8
+			// 孩子为 InternalNode 时，两侧各有一个 CSCR 存放交集
+			if level >= 2 {
+				addCSCR(intersection, level, 1<<(level-1))
+				addCSCR(intersection, level, 1<<(level-1))
+			}
+		}
+		if level == cfg.MaxLevel-1 {
+			// RootNode 的 CSCR 存放剩余的账户（不包括最后一次合并的交集）
+			addCSCR(int(math.Round(2*size-2*float64(intersection))), cfg.MaxLevel, leafNum)
+		}
+		size = 2*size - float64(intersection)
+	}
+	est.BitSizeBytes = (bfBits + cscBits) * treeNum
+	est.HashMapEntries *= treeNum
+	est.Bytes = est.BitSizeBytes + est.HashMapEntries*csctree.HashMapEntryBytes
+	if sketchElements > 0 {
+		est.CSCRFpr = weightedFpr / float64(sketchElements)
+	}
+	est.Fpr = 1 - (1-est.BfFpr)*(1-est.CSCRFpr)
+	return est
+}
+
+// 与 NewCSCRWithEstimation 一致的 CSCR 大小，以及与 CSC.FalsePositiveRate 一致的误判率
+func cscrModel(cfg *config.CSCTreeConfig, elementNum int, rangeSize int) (int, float64) {
+	partitionNum := max(rangeSize/cfg.MaxElementNumPerPar+1, cfg.PartitionNum)
+	bucketNum := 1 << basicfilter.EstimateBucketPow(elementNum, cfg.FingerprintNum, partitionNum)
+	bits := bucketNum * cfg.FingerprintNum * cfg.FingerprintSize * cfg.RepetitionNum
+	occupied := float64(elementNum) / float64(bucketNum)
+	fpr := 1 - math.Pow(1-math.Pow(2, -float64(cfg.FingerprintSize)), 2*occupied)
+	// R 个 CSC 的结果取交集
+	return bits, math.Pow(fpr, float64(cfg.RepetitionNum))
+}
+
+// 返回满足预算且误判率最低的配置（误判率相同时选择内存更小的），没有配置满足预算时返回错误
+func (t *Tuner) Tune(budget Budget) (*Estimate, error) {
+	candidates := t.Candidates(budget.BlockNum)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no valid configuration in the search space")
+	}
+	fits := make([]*Estimate, 0)
+	for _, est := range candidates {
+		if est.Bytes <= budget.Bytes {
+			fits = append(fits, est)
+		}
+	}
+	if len(fits) == 0 {
+		smallest := candidates[0]
+		for _, est := range candidates {
+			if est.Bytes < smallest.Bytes {
+				smallest = est
+			}
+		}
+		return nil, fmt.Errorf("no configuration fits in %d bytes for %d blocks, smallest needs %d bytes", budget.Bytes, budget.BlockNum, smallest.Bytes)
+	}
+	sort.SliceStable(fits, func(i, j int) bool {
+		if fits[i].Fpr != fits[j].Fpr {
+			return fits[i].Fpr < fits[j].Fpr
+		}
+		return fits[i].Bytes < fits[j].Bytes
+	})
+	return fits[0], nil
+}
+
+// 估计 SearchSpace 中所有合法配置
+func (t *Tuner) Candidates(blockNum int) []*Estimate {
+	res := make([]*Estimate, 0)
+	for _, fpr := range t.Space.BfFalsePositiveRate {
+		for _, f := range t.Space.FingerprintSize {
+			for _, r := range t.Space.RepetitionNum {
+				for _, par := range t.Space.PartitionNum {
+					for _, sketchLevel := range t.Space.SketchLevel {
+						cfg := *t.Base
+						cfg.BfFalsePositiveRate = fpr
+						cfg.FingerprintSize = f
+						cfg.RepetitionNum = r
+						cfg.PartitionNum = par
+						cfg.SketchLevel = sketchLevel
+						if cfg.Validate() != nil {
+							continue
+						}
+						res = append(res, t.Estimate(&cfg, blockNum))
+					}
+				}
+			}
+		}
+	}
+	return res
+}
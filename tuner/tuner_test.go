@@ -0,0 +1,87 @@
+package tuner
+
+import (
+	"testing"
+
+	"github.com/liuys-dase/csc-tree/config"
+	"github.com/liuys-dase/csc-tree/context"
+	"github.com/liuys-dase/csc-tree/csctree"
+	"github.com/liuys-dase/csc-tree/generator"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	blocks [][]string
+}
+
+func (r *recorder) AddwithBlock(blockNumber int, txnStrings []string) {
+	r.blocks = append(r.blocks, txnStrings)
+}
+
+func sampleBlocks(blockNum int) [][]string {
+	cfg := generator.DefaultConfig()
+	cfg.TxnsPerBlock = 50
+	r := &recorder{}
+	g, _ := generator.NewGenerator(cfg)
+	g.Feed(r, blockNum)
+	return r.blocks
+}
+
+func baseConfig() *config.CSCTreeConfig {
+	cfg := config.DefaultCSCTreeConfig()
+	cfg.MaxLevel = 7
+	return cfg
+}
+
+func TestCalibrate(t *testing.T) {
+	_, err := Calibrate(sampleBlocks(1), csctree.SENDER)
+	assert.NotNil(t, err)
+	p, err := Calibrate(sampleBlocks(64), csctree.SENDER)
+	assert.Nil(t, err)
+	assert.True(t, p.AccountsPerBlock > 0 && p.AccountsPerBlock <= 50)
+	// 64 个区块两两合并 6 次
+	assert.Equal(t, 6, len(p.Overlap))
+	for _, o := range p.Overlap {
+		assert.True(t, o > 0 && o < 1)
+	}
+}
+
+// 解析模型与实际构建的 CSCForest 的 GetBitSize 接近
+func TestEstimate(t *testing.T) {
+	blocks := sampleBlocks(256)
+	p, _ := Calibrate(blocks[:64], csctree.SENDER)
+	for _, sketchLevel := range []int{0, 2} {
+		cfg := baseConfig()
+		cfg.SketchLevel = sketchLevel
+		est := NewTuner(cfg, p).Estimate(cfg, len(blocks))
+		ctx, err := context.NewContextWithConfig(cfg)
+		assert.Nil(t, err)
+		forest := csctree.NewCSCForest(ctx)
+		for i, txns := range blocks {
+			forest.AddwithBlock(i, txns)
+		}
+		actual := 0
+		for _, tree := range forest.CSCForest {
+			if !tree.IsEmpty() {
+				actual += tree.GetBitSize()
+			}
+		}
+		assert.InEpsilon(t, actual, est.BitSizeBytes, 0.1)
+	}
+}
+
+func TestTune(t *testing.T) {
+	tuner := NewTuner(baseConfig(), NewProfile(32, 0.3))
+	small, err := tuner.Tune(Budget{Bytes: 100000, BlockNum: 512})
+	assert.Nil(t, err)
+	assert.Nil(t, small.Config.Validate())
+	assert.True(t, small.Bytes <= 100000)
+	large, err := tuner.Tune(Budget{Bytes: 1000000, BlockNum: 512})
+	assert.Nil(t, err)
+	assert.True(t, large.Fpr <= small.Fpr)
+	// 其余参数保持不变
+	assert.Equal(t, 7, large.Config.MaxLevel)
+
+	_, err = tuner.Tune(Budget{Bytes: 100, BlockNum: 512})
+	assert.ErrorContains(t, err, "smallest needs")
+}